@@ -0,0 +1,59 @@
+// Command returnlinter-lsp is a language server that shows the findings of
+// the returnlinter rules in an editor.
+//
+// gopls cannot load analyzers from outside x/tools, so the server runs next
+// to it as a second language server for Go files. It speaks the Language
+// Server Protocol over stdin and stdout, analyzes the package of a file
+// when the file is opened or saved, publishes the findings as diagnostics
+// and offers their suggested fixes as quick fix code actions.
+//
+// The initializationOptions of the client select the rules and configure
+// them, using the names of the gopls settings where there is one:
+//
+//	{
+//		"analyses": {"statuscode": false, "contenttype": true},
+//		"loggers": ["log/slog"],
+//		"responseHelpers": ["example.com/api.JSONError"],
+//		"uncheckedWriteErrorStatus": true,
+//		"contentTypeHelpers": ["example.com/api.SetJSON"],
+//		"statusPolicies": [{"name": "middleware-rejects", "kinds": ["middleware"], "allow": ["4xx", "5xx"]}]
+//	}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+)
+
+func main() {
+	var configFlags analyzer.ConfigFlags
+	configFlags.Register(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: returnlinter-lsp [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Serves the Language Server Protocol over stdin and stdout.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := configFlags.Config()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	s, err := newServer(newConn(os.Stdin, os.Stdout), cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := s.serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "returnlinter-lsp: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The subset of the Language Server Protocol the server speaks. Field names
+// follow the specification.
+
+// position is a zero-based line and UTF-16 column
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+func (p position) before(q position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Character < q.Character
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// overlaps reports whether r and s share a position, counting a position
+// at the end of either one
+func (r textRange) overlaps(s textRange) bool {
+	return !r.End.before(s.Start) && !s.End.before(r.Start)
+}
+
+type location struct {
+	URI   string    `json:"uri"`
+	Range textRange `json:"range"`
+}
+
+// severityWarning is the severity of every diagnostic
+const severityWarning = 2
+
+type diagnostic struct {
+	Range              textRange            `json:"range"`
+	Severity           int                  `json:"severity"`
+	Code               string               `json:"code,omitempty"`
+	CodeDescription    *codeDescription     `json:"codeDescription,omitempty"`
+	Source             string               `json:"source"`
+	Message            string               `json:"message"`
+	RelatedInformation []relatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type codeDescription struct {
+	Href string `json:"href"`
+}
+
+type relatedInformation struct {
+	Location location `json:"location"`
+	Message  string   `json:"message"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+type textEdit struct {
+	Range   textRange `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+type workspaceEdit struct {
+	Changes map[string][]textEdit `json:"changes"`
+}
+
+// codeActionQuickFix is the kind of the code actions the server offers
+const codeActionQuickFix = "quickfix"
+
+type codeAction struct {
+	Title       string         `json:"title"`
+	Kind        string         `json:"kind"`
+	Diagnostics []diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *workspaceEdit `json:"edit,omitempty"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type initializeParams struct {
+	RootURI               string          `json:"rootUri"`
+	InitializationOptions json.RawMessage `json:"initializationOptions"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+	ServerInfo   serverInfo         `json:"serverInfo"`
+}
+
+type serverCapabilities struct {
+	TextDocumentSync   textDocumentSyncOptions `json:"textDocumentSync"`
+	CodeActionProvider codeActionOptions       `json:"codeActionProvider"`
+}
+
+type textDocumentSyncOptions struct {
+	OpenClose bool `json:"openClose"`
+	// Change is the kind of didChange notifications wanted; the server
+	// analyzes saved files only, so it wants none
+	Change int         `json:"change"`
+	Save   saveOptions `json:"save"`
+}
+
+type saveOptions struct {
+	IncludeText bool `json:"includeText"`
+}
+
+type codeActionOptions struct {
+	CodeActionKinds []string `json:"codeActionKinds"`
+}
+
+type serverInfo struct {
+	Name string `json:"name"`
+}
+
+// textDocumentParams holds the fields the server reads from the params of
+// didOpen, didSave and didClose notifications
+type textDocumentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type codeActionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Range        textRange              `json:"range"`
+	Context      struct {
+		Diagnostics []diagnostic `json:"diagnostics"`
+		Only        []string     `json:"only"`
+	} `json:"context"`
+}
+
+// messageError is the type of the window/logMessage notifications sent
+const messageError = 1
+
+type logMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// uriToPath returns the file named by a file:// URI
+func uriToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// pathToURI returns the file:// URI of an absolute file name
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// lspPosition converts a byte offset in content to a position. Offsets past
+// the end are clamped.
+func lspPosition(content []byte, offset int) position {
+	offset = min(max(offset, 0), len(content))
+	var p position
+	lineStart := 0
+	for i := 0; i < offset; i++ {
+		if content[i] == '\n' {
+			p.Line++
+			lineStart = i + 1
+		}
+	}
+	for line := content[lineStart:offset]; len(line) > 0; {
+		r, size := utf8.DecodeRune(line)
+		p.Character += utf16.RuneLen(r)
+		line = line[size:]
+	}
+	return p
+}
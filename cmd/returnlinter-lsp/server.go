@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// settings are the initializationOptions the server reads, named like the
+// gopls settings they mirror. Unset fields keep the command-line defaults.
+type settings struct {
+	// Analyses enables or disables rules by analyzer name, as the gopls
+	// "analyses" setting does. Optional rules are off unless enabled here.
+	// Names of other analyzers are ignored, so the gopls settings can be
+	// shared.
+	Analyses           map[string]bool `json:"analyses"`
+	Loggers            []string        `json:"loggers"`
+	ResponseHelpers    []string        `json:"responseHelpers"`
+	ContentTypeHelpers []string        `json:"contentTypeHelpers"`
+	// StatusPolicies holds the status policies, with the field names of
+	// the -status-policies file
+	StatusPolicies []analyzer.StatusPolicy `json:"statusPolicies"`
+	// UncheckedWriteErrorStatus limits the uncheckedwrite rule to writes
+	// that may follow an error status
+	UncheckedWriteErrorStatus *bool `json:"uncheckedWriteErrorStatus"`
+}
+
+// server is a language server publishing the findings of the rules. It
+// handles one message at a time; analysis runs when a file is opened or
+// saved.
+type server struct {
+	conn *conn
+	// config holds the defaults from the command line
+	config    analyzer.Config
+	analyzers []*analysis.Analyzer
+	// published holds the diagnostics last published for each file, by
+	// URI
+	published map[string][]published
+	shutdown  bool
+}
+
+// published is a diagnostic sent to the client, with the code actions
+// applying its suggested fixes
+type published struct {
+	diagnostic diagnostic
+	actions    []codeAction
+}
+
+func newServer(c *conn, cfg analyzer.Config) (*server, error) {
+	s := &server{conn: c, config: cfg, published: make(map[string][]published)}
+	if err := s.configure(nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// errExitBeforeShutdown is returned by serve when the client asks the server
+// to exit without shutting it down first
+var errExitBeforeShutdown = errors.New("exit notification before shutdown request")
+
+// serve handles messages until the exit notification or the end of input
+func (s *server) serve() error {
+	for {
+		msg, err := s.conn.read()
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			// The ID of a malformed message is unknown
+			if err := s.conn.replyError(json.RawMessage("null"), rpcErr.Code, "%s", rpcErr.Message); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitBeforeShutdown
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers one message. It only returns errors writing to the client.
+func (s *server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.conn.replyError(msg.ID, codeInvalidParams, "%v", err)
+		}
+		if err := s.configure(params.InitializationOptions); err != nil {
+			return s.conn.replyError(msg.ID, codeInvalidParams, "initializationOptions: %v", err)
+		}
+		return s.conn.reply(msg.ID, initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync: textDocumentSyncOptions{OpenClose: true, Save: saveOptions{}},
+				CodeActionProvider: codeActionOptions{
+					CodeActionKinds: []string{codeActionQuickFix},
+				},
+			},
+			ServerInfo: serverInfo{Name: "returnlinter-lsp"},
+		})
+
+	case "shutdown":
+		s.shutdown = true
+		return s.conn.reply(msg.ID, nil)
+
+	case "textDocument/didOpen", "textDocument/didSave":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.logf(messageError, "%s: %v", msg.Method, err)
+		}
+		return s.check(params.TextDocument.URI)
+
+	case "textDocument/didClose":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.logf(messageError, "%s: %v", msg.Method, err)
+		}
+		uri := params.TextDocument.URI
+		delete(s.published, uri)
+		return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: []diagnostic{}})
+
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.conn.replyError(msg.ID, codeInvalidParams, "%v", err)
+		}
+		return s.conn.reply(msg.ID, s.codeActions(params))
+	}
+
+	if msg.isRequest() {
+		return s.conn.replyError(msg.ID, codeMethodNotFound, "method %s is not supported", msg.Method)
+	}
+	// Other notifications, such as initialized and didChange, need no
+	// answer
+	return nil
+}
+
+// configure selects the analyzers to run from the initializationOptions of
+// the client, or from the command-line defaults if there are none
+func (s *server) configure(options json.RawMessage) error {
+	var opts settings
+	if len(options) > 0 {
+		if err := json.Unmarshal(options, &opts); err != nil {
+			return err
+		}
+	}
+	cfg := s.config
+	if opts.Loggers != nil {
+		cfg.Loggers = opts.Loggers
+	}
+	if opts.ResponseHelpers != nil {
+		cfg.ResponseHelpers = opts.ResponseHelpers
+	}
+	if opts.ContentTypeHelpers != nil {
+		cfg.ContentTypeHelpers = opts.ContentTypeHelpers
+	}
+	if opts.StatusPolicies != nil {
+		cfg.StatusPolicies = opts.StatusPolicies
+	}
+	if opts.UncheckedWriteErrorStatus != nil {
+		cfg.UncheckedWriteErrorStatusOnly = *opts.UncheckedWriteErrorStatus
+	}
+
+	linter, err := analyzer.New(cfg)
+	if err != nil {
+		return err
+	}
+	s.analyzers = nil
+	for i, a := range linter.Analyzers {
+		enabled, ok := opts.Analyses[a.Name]
+		if !ok {
+			enabled = !analyzer.Rules[i].Optional
+		}
+		if enabled {
+			s.analyzers = append(s.analyzers, a)
+		}
+	}
+	return nil
+}
+
+// check analyzes the package of the file named by uri, along with its
+// tests, and publishes the diagnostics of every file in its directory.
+// Files of packages that do not type-check keep their previous diagnostics.
+func (s *server) check(uri string) error {
+	path, ok := uriToPath(uri)
+	if !ok {
+		return s.logf(messageError, "%s: not a file URI", uri)
+	}
+	dir := filepath.Dir(path)
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax, Dir: dir, Tests: true}, "file="+path)
+	if err != nil {
+		return s.logf(messageError, "loading %s: %v", path, err)
+	}
+	graph, err := checker.Analyze(s.analyzers, pkgs, nil)
+	if err != nil {
+		return s.logf(messageError, "analyzing %s: %v", path, err)
+	}
+
+	failed := make(map[*packages.Package]bool)
+	for _, act := range graph.Roots {
+		if act.Err != nil && !failed[act.Package] {
+			failed[act.Package] = true
+			if err := s.logf(messageError, "%s: %v", act.Package.ID, act.Err); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Every file of the directory is published, so fixed files are cleared
+	files := make(map[string][]published)
+	for _, pkg := range pkgs {
+		if failed[pkg] {
+			continue
+		}
+		for _, name := range pkg.GoFiles {
+			if filepath.Dir(name) == dir {
+				files[pathToURI(name)] = nil
+			}
+		}
+	}
+	src := make(sources)
+	// A package and its test variant share files
+	seen := make(map[string]bool)
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			continue
+		}
+		for _, diag := range act.Diagnostics {
+			p, ok := src.convert(act.Package.Fset, diag)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%s:%v:%s", p.uri, p.diagnostic.Range, p.diagnostic.Message)
+			if _, ok := files[p.uri]; !ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			files[p.uri] = append(files[p.uri], p.published)
+		}
+	}
+
+	uris := make([]string, 0, len(files))
+	for uri := range files {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		s.published[uri] = files[uri]
+		diagnostics := []diagnostic{}
+		for _, p := range files[uri] {
+			diagnostics = append(diagnostics, p.diagnostic)
+		}
+		if err := s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// codeActions returns the quick fixes of the published diagnostics
+// overlapping the requested range
+func (s *server) codeActions(params codeActionParams) []codeAction {
+	actions := []codeAction{}
+	if only := params.Context.Only; len(only) > 0 {
+		wanted := false
+		for _, kind := range only {
+			wanted = wanted || kind == codeActionQuickFix
+		}
+		if !wanted {
+			return actions
+		}
+	}
+	for _, p := range s.published[params.TextDocument.URI] {
+		if p.diagnostic.Range.overlaps(params.Range) {
+			actions = append(actions, p.actions...)
+		}
+	}
+	return actions
+}
+
+// logf shows a message in the client's log
+func (s *server) logf(typ int, format string, args ...any) error {
+	return s.conn.notify("window/logMessage", logMessageParams{Type: typ, Message: fmt.Sprintf(format, args...)})
+}
+
+// sources holds the contents of files by name, read on demand to convert
+// byte offsets to positions
+type sources map[string][]byte
+
+func (src sources) location(fset *token.FileSet, pos, end token.Pos) (location, bool) {
+	if !pos.IsValid() {
+		return location{}, false
+	}
+	if !end.IsValid() {
+		end = pos
+	}
+	start := fset.Position(pos)
+	content, ok := src[start.Filename]
+	if !ok {
+		var err error
+		if content, err = os.ReadFile(start.Filename); err != nil {
+			return location{}, false
+		}
+		src[start.Filename] = content
+	}
+	return location{
+		URI: pathToURI(start.Filename),
+		Range: textRange{
+			Start: lspPosition(content, start.Offset),
+			End:   lspPosition(content, fset.Position(end).Offset),
+		},
+	}, true
+}
+
+// converted is a diagnostic ready to be published for the file at uri
+type converted struct {
+	uri string
+	published
+}
+
+// convert turns an analysis diagnostic into a published diagnostic with a
+// code action for each of its suggested fixes
+func (src sources) convert(fset *token.FileSet, diag analysis.Diagnostic) (converted, bool) {
+	loc, ok := src.location(fset, diag.Pos, diag.End)
+	if !ok {
+		return converted{}, false
+	}
+	d := diagnostic{
+		Range:    loc.Range,
+		Severity: severityWarning,
+		Code:     diag.Category,
+		Source:   "returnlinter",
+		Message:  diag.Message,
+	}
+	if diag.URL != "" {
+		d.CodeDescription = &codeDescription{Href: diag.URL}
+	}
+	for _, related := range diag.Related {
+		if loc, ok := src.location(fset, related.Pos, related.End); ok {
+			d.RelatedInformation = append(d.RelatedInformation, relatedInformation{Location: loc, Message: related.Message})
+		}
+	}
+
+	var actions []codeAction
+	for i, fix := range diag.SuggestedFixes {
+		edit := &workspaceEdit{Changes: make(map[string][]textEdit)}
+		for _, e := range fix.TextEdits {
+			loc, ok := src.location(fset, e.Pos, e.End)
+			if !ok {
+				edit = nil
+				break
+			}
+			edit.Changes[loc.URI] = append(edit.Changes[loc.URI], textEdit{Range: loc.Range, NewText: string(e.NewText)})
+		}
+		if edit == nil {
+			continue
+		}
+		actions = append(actions, codeAction{
+			Title:       fix.Message,
+			Kind:        codeActionQuickFix,
+			Diagnostics: []diagnostic{d},
+			IsPreferred: i == 0,
+			Edit:        edit,
+		})
+	}
+	return converted{uri: loc.URI, published: published{diagnostic: d, actions: actions}}, true
+}
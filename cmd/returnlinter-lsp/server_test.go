@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3-2-1-contact/return-linter/internal/testmodule"
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+)
+
+// app is a module with RL001, RL002 and RL007 findings in app.go, the
+// second one with a fix. The emoji takes two UTF-16 code units but four
+// bytes.
+var app = strings.Replace(testmodule.Auth, "w.WriteHeader(http.StatusUnauthorized)", `w.Header().Set("X-Reason", "🔑"); w.WriteHeader(401)`, 1)
+
+// client drives a server running in-process over a pair of pipes
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// incoming receives every message from the server, read ahead so that
+	// the server never blocks writing notifications
+	incoming chan *message
+	// pending holds notifications received while waiting for a response
+	pending []*message
+	done    chan error
+}
+
+func startServer(t *testing.T, cfg analyzer.Config) *client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	s, err := newServer(newConn(serverR, serverW), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &client{t: t, conn: newConn(clientR, clientW), incoming: make(chan *message, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- s.serve()
+		serverW.Close()
+	}()
+	go func() {
+		defer close(c.incoming)
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.incoming <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		clientW.Close()
+		clientR.Close()
+	})
+	return c
+}
+
+// receive returns the next message from the server
+func (c *client) receive() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.incoming:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(time.Minute):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// call sends a request and decodes the result of its response into result
+func (c *client) call(method string, params, result any) *rpcError {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	for {
+		msg := c.receive()
+		if msg.Method != "" {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		if string(msg.ID) != strings.TrimSpace(mustMarshal(c.t, id)) {
+			c.t.Fatalf("response to %s has ID %s, want %d", method, msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s result: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) send(msg any) {
+	c.t.Helper()
+	if err := c.conn.write(msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics waits for the diagnostics published for uri
+func (c *client) diagnostics(uri string) []diagnostic {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.pending) > 0 {
+			msg, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			msg = c.receive()
+		}
+		if msg.Method == "window/logMessage" {
+			c.t.Logf("server log: %s", msg.Params)
+			continue
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			c.t.Fatalf("unexpected message %s", msg.Method)
+		}
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatal(err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+// exit shuts the server down and checks that it stops cleanly
+func (c *client) exit() {
+	c.t.Helper()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatalf("shutdown: %v", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("serve: %v", err)
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// writeModule writes the module of the tests and returns the URI of app.go
+func writeModule(t *testing.T, src string) (path, uri string) {
+	t.Helper()
+	path = filepath.Join(testmodule.Write(t, map[string]string{"app.go": src}), "app.go")
+	return path, pathToURI(path)
+}
+
+func textDocument(uri string) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}}
+}
+
+func TestServer(t *testing.T) {
+	path, uri := writeModule(t, app)
+	c := startServer(t, analyzer.Config{})
+
+	var init initializeResult
+	if err := c.call("initialize", map[string]any{"rootUri": pathToURI(filepath.Dir(path))}, &init); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if !init.Capabilities.TextDocumentSync.OpenClose || !reflect.DeepEqual(init.Capabilities.CodeActionProvider.CodeActionKinds, []string{"quickfix"}) {
+		t.Errorf("capabilities = %+v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]any{})
+
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{
+		"uri": uri, "languageId": "go", "version": 1, "text": app,
+	}})
+	diags := c.diagnostics(uri)
+	var got []string
+	for _, d := range diags {
+		got = append(got, mustMarshal(t, d.Range)+" "+d.Code+" "+d.Message)
+	}
+	want := []string{
+		`{"start":{"line":7,"character":37},"end":{"line":7,"character":55}} RL001 WriteHeader call not immediately followed by return statement`,
+		`{"start":{"line":7,"character":51},"end":{"line":7,"character":54}} RL002 use http.StatusUnauthorized instead of the integer literal 401`,
+		`{"start":{"line":9,"character":2},"end":{"line":9,"character":2}} RL007 next handler called after the response status was already written`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if d := diags[0]; d.Source != "returnlinter" || d.Severity != severityWarning || d.CodeDescription == nil || !strings.HasSuffix(d.CodeDescription.Href, "RL001.md") {
+		t.Errorf("RL001 diagnostic = %+v", d)
+	}
+	if related := diags[0].RelatedInformation; len(related) == 0 || related[len(related)-1].Message != "in middleware Auth" {
+		t.Errorf("RL001 related information = %+v", related)
+	}
+
+	// Only the fix of RL002 is offered for the literal
+	var actions []codeAction
+	if err := c.call("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        diags[1].Range,
+		"context":      map[string]any{"diagnostics": []diagnostic{diags[1]}},
+	}, &actions); err != nil {
+		t.Fatalf("codeAction: %v", err)
+	}
+	if len(actions) != 1 {
+		t.Fatalf("code actions = %+v, want 1", actions)
+	}
+	action := actions[0]
+	wantEdit := map[string][]textEdit{uri: {{Range: diags[1].Range, NewText: "http.StatusUnauthorized"}}}
+	if action.Title != "Replace 401 with http.StatusUnauthorized" || action.Kind != "quickfix" || !action.IsPreferred || !reflect.DeepEqual(action.Edit.Changes, wantEdit) {
+		t.Errorf("code action = %+v", action)
+	}
+
+	// Refactorings are not offered
+	if err := c.call("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        diags[1].Range,
+		"context":      map[string]any{"diagnostics": []diagnostic{}, "only": []string{"refactor"}},
+	}, &actions); err != nil || len(actions) != 0 {
+		t.Errorf("refactor code actions = %+v, %v; want none", actions, err)
+	}
+
+	// Saving the fixed file clears its diagnostics
+	fixed := strings.Replace(app, `w.WriteHeader(401)`, "w.WriteHeader(http.StatusUnauthorized)\n\t\t\treturn", 1)
+	if err := os.WriteFile(path, []byte(fixed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("textDocument/didSave", textDocument(uri))
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("diagnostics after fixing = %+v, want none", diags)
+	}
+
+	c.notify("textDocument/didClose", textDocument(uri))
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("diagnostics after closing = %+v, want none", diags)
+	}
+
+	if err := c.call("textDocument/hover", map[string]any{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("hover: got error %v, want method not found", err)
+	}
+	c.exit()
+}
+
+// TestServerSettings checks that initializationOptions select and
+// configure the rules
+func TestServerSettings(t *testing.T) {
+	src := strings.Replace(app, `w.Header().Set("X-Reason", "🔑"); w.WriteHeader(401)`, "w.WriteHeader(401)\n\t\t\tslog.Warn(\"unauthorized\")\n\t\t\treturn", 1)
+	src = strings.Replace(src, `import "net/http"`, "import (\n\t\"log/slog\"\n\t\"net/http\"\n)", 1)
+	_, uri := writeModule(t, src)
+	c := startServer(t, analyzer.Config{})
+
+	if err := c.call("initialize", map[string]any{
+		"initializationOptions": map[string]any{"analyses": map[string]bool{"statuscode": false, "unusedparams": true}, "loggers": []string{"log/slog"}},
+	}, nil); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	c.notify("textDocument/didOpen", textDocument(uri))
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("diagnostics = %+v, want none", diags)
+	}
+	c.exit()
+
+	c = startServer(t, analyzer.Config{})
+	err := c.call("initialize", map[string]any{"initializationOptions": map[string]any{"responseHelpers": []string{"JSONError"}}}, nil)
+	if err == nil || err.Code != codeInvalidParams || !strings.Contains(err.Message, `response helper "JSONError"`) {
+		t.Errorf("initialize with an invalid helper: got error %v", err)
+	}
+	c.exit()
+
+	c = startServer(t, analyzer.Config{})
+	err = c.call("initialize", map[string]any{"initializationOptions": map[string]any{"statusPolicies": []map[string]any{{"name": "open"}}}}, nil)
+	if err == nil || err.Code != codeInvalidParams || !strings.Contains(err.Message, `status policy "open" allows every status`) {
+		t.Errorf("initialize with an invalid status policy: got error %v", err)
+	}
+	c.exit()
+}
+
+// TestOptionalAnalyses checks that optional rules only run when the
+// analyses setting enables them
+func TestOptionalAnalyses(t *testing.T) {
+	for _, tt := range []struct {
+		options string
+		want    bool
+	}{
+		{``, false},
+		{`{"analyses": {"statuscode": false}}`, false},
+		{`{"analyses": {"contenttype": true}}`, true},
+	} {
+		s, err := newServer(newConn(strings.NewReader(""), io.Discard), analyzer.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.configure(json.RawMessage(tt.options)); err != nil {
+			t.Fatalf("configure(%s): %v", tt.options, err)
+		}
+		got := false
+		for _, a := range s.analyzers {
+			got = got || a.Name == "contenttype"
+		}
+		if got != tt.want {
+			t.Errorf("configure(%s) enables contenttype: %t, want %t", tt.options, got, tt.want)
+		}
+	}
+}
+
+// TestExitBeforeShutdown checks that serve reports a client exiting
+// without shutting the server down, which LSP servers signal with their
+// exit code
+func TestExitBeforeShutdown(t *testing.T) {
+	c := startServer(t, analyzer.Config{})
+	c.notify("exit", nil)
+	if err := <-c.done; err != errExitBeforeShutdown {
+		t.Errorf("serve: got %v, want %v", err, errExitBeforeShutdown)
+	}
+}
+
+// TestMalformedMessage checks that a body that is not JSON is answered with
+// a parse error and the server keeps serving
+func TestMalformedMessage(t *testing.T) {
+	c := startServer(t, analyzer.Config{})
+	if _, err := io.WriteString(c.conn.w, "Content-Length: 5\r\n\r\n{oops"); err != nil {
+		t.Fatal(err)
+	}
+	msg := c.receive()
+	if string(msg.ID) != "null" || msg.Error == nil || msg.Error.Code != codeParseError {
+		t.Errorf("response = %+v, want a parse error", msg)
+	}
+	c.exit()
+}
+
+// TestContentLength checks that a Content-Length that is not a size the
+// server can allocate is rejected before reading the body
+func TestContentLength(t *testing.T) {
+	for _, length := range []string{"-1", "x", "1099511627776"} {
+		c := newConn(strings.NewReader("Content-Length: "+length+"\r\n\r\n{}"), io.Discard)
+		if msg, err := c.read(); err == nil || !strings.Contains(err.Error(), "Content-Length") {
+			t.Errorf("Content-Length %s: read = %+v, %v, want an error", length, msg, err)
+		}
+	}
+}
+
+func TestLSPPosition(t *testing.T) {
+	content := []byte("ab\n🔑é=x\n")
+	for _, tt := range []struct {
+		offset int
+		want   position
+	}{
+		{0, position{0, 0}},
+		{2, position{0, 2}},
+		{3, position{1, 0}},
+		{7, position{1, 2}},   // after the emoji: two UTF-16 units
+		{9, position{1, 3}},   // after é: one unit for two bytes
+		{100, position{2, 0}}, // clamped to the end
+	} {
+		if got := lspPosition(content, tt.offset); got != tt.want {
+			t.Errorf("lspPosition(%d) = %+v, want %+v", tt.offset, got, tt.want)
+		}
+	}
+}
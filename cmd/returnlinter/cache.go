@@ -0,0 +1,314 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// metadataMode loads what is needed to compute cache keys, without parsing
+// or type-checking anything
+const metadataMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedDeps
+
+// cache stores the findings and facts of each analyzed package on disk, so
+// that later runs can skip packages that did not change. Entries are keyed
+// by the contents of the package, the keys of its dependencies, the linter
+// binary and the enabled rules.
+type cache struct {
+	dir string
+	// salt covers everything besides the packages that affects findings
+	salt []byte
+}
+
+// cacheEntry is the stored result of analyzing one package
+type cacheEntry struct {
+	Package string    `json:"package"`
+	Created time.Time `json:"created"`
+	// Root is set when the package was analyzed by every rule rather than
+	// only for the facts needed by the packages importing it, so that
+	// Findings are complete
+	Root     bool         `json:"root"`
+	Findings []finding    `json:"findings"`
+	Facts    []cachedFact `json:"facts"`
+}
+
+// defaultCacheDir returns $XDG_CACHE_HOME/returnlinter, or the platform's
+// equivalent
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "returnlinter")
+}
+
+// openCache returns the cache in dir for a run of analyzers configured by
+// cfg
+func openCache(dir string, analyzers []*analysis.Analyzer, cfg analyzer.Config) (*cache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no cache directory: set $XDG_CACHE_HOME or -cache-dir")
+	}
+	version, err := executableHash()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, a := range analyzers {
+		names = append(names, a.Name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "returnlinter %s\n", version)
+	fmt.Fprintf(h, "analyzers %s\n", strings.Join(names, ","))
+	fmt.Fprintf(h, "response helpers %q\n", cfg.ResponseHelpers)
+	fmt.Fprintf(h, "loggers %q\n", cfg.Loggers)
+	fmt.Fprintf(h, "uncheckedwrite error status only %t\n", cfg.UncheckedWriteErrorStatusOnly)
+	fmt.Fprintf(h, "content type helpers %q\n", cfg.ContentTypeHelpers)
+	policies, err := json.Marshal(cfg.StatusPolicies)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(h, "status policies %s\n", policies)
+	return &cache{dir: dir, salt: h.Sum(nil)}, nil
+}
+
+// executableHash identifies the running linter binary by its contents, as
+// go vet does for vet tools
+func executableHash() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// keys computes the cache key of every package in the import graph of
+// pkgs, by package ID. Packages whose files cannot be read have no key.
+func (c *cache) keys(pkgs []*packages.Package) map[string]string {
+	keys := make(map[string]string)
+	var visit func(pkg *packages.Package) string
+	visit = func(pkg *packages.Package) string {
+		if key, ok := keys[pkg.ID]; ok {
+			return key
+		}
+		// Import cycles are reported by the loader; never cache them
+		keys[pkg.ID] = ""
+
+		h := sha256.New()
+		h.Write(c.salt)
+		fmt.Fprintf(h, "package %s\n", pkg.ID)
+
+		files := append(append([]string(nil), pkg.GoFiles...), pkg.CompiledGoFiles...)
+		sort.Strings(files)
+		for i, file := range files {
+			if i > 0 && file == files[i-1] {
+				continue
+			}
+			sum, err := fileHash(file)
+			if err != nil {
+				return ""
+			}
+			fmt.Fprintf(h, "file %s %s\n", file, sum)
+		}
+
+		paths := make([]string, 0, len(pkg.Imports))
+		for path := range pkg.Imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			// A dependency's facts are determined by its own key
+			dep := visit(pkg.Imports[path])
+			if dep == "" {
+				return ""
+			}
+			fmt.Fprintf(h, "import %s %s\n", path, dep)
+		}
+
+		key := hex.EncodeToString(h.Sum(nil))
+		keys[pkg.ID] = key
+		return key
+	}
+	for _, pkg := range pkgs {
+		visit(pkg)
+	}
+	return keys
+}
+
+func fileHash(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// path returns the file holding the entry for key
+func (c *cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get returns the entry for key, or nil if there is none. Reading an entry
+// marks it as used, for -cache-prune.
+func (c *cache) get(key string) *cacheEntry {
+	if key == "" {
+		return nil
+	}
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return &entry
+}
+
+// put stores entry under key, replacing any previous entry atomically
+func (c *cache) put(key string, entry *cacheEntry) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cacheFile is an entry found by walking the cache directory
+type cacheFile struct {
+	path    string
+	size    int64
+	lastUse time.Time
+}
+
+// files lists the entries in the cache
+func (c *cache) files() ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == c.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), lastUse: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// info writes a summary of the cache followed by one line per entry
+func (c *cache) info(w io.Writer) error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].lastUse.After(files[j].lastUse)
+	})
+
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	fmt.Fprintf(w, "cache: %s\n", c.dir)
+	fmt.Fprintf(w, "entries: %d (%d bytes)\n", len(files), size)
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return err
+		}
+		var entry cacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			fmt.Fprintf(w, "%s: invalid entry: %v\n", f.path, err)
+			continue
+		}
+		key := strings.TrimSuffix(filepath.Base(f.path), ".json")
+		findings := "-"
+		if entry.Root {
+			findings = fmt.Sprint(len(entry.Findings))
+		}
+		fmt.Fprintf(w, "%s %s findings=%s facts=%d used=%s\n",
+			key[:12], entry.Package, findings, len(entry.Facts), f.lastUse.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// prune removes the entries that were not used within age, and returns how
+// many it removed
+func (c *cache) prune(age time.Duration) (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-age)
+	removed := 0
+	for _, f := range files {
+		if age > 0 && f.lastUse.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
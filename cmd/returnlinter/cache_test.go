@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/internal/testmodule"
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestCache(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{
+		"app.go":     testmodule.Auth,
+		"lib/lib.go": "package lib\n\nfunc Add(a, b int) int { return a + b }\n",
+	})
+	cacheDir := t.TempDir()
+	t.Chdir(module)
+
+	lint := func() string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-nextafterreject", "-cache-dir", cacheDir, "./..."}, &stdout, &stderr)
+		if code != exitFindings {
+			t.Fatalf("exit code %d, want %d; stderr:\n%s", code, exitFindings, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	first := lint()
+	if !strings.Contains(first, "next handler called after") {
+		t.Fatalf("first run reported:\n%s", first)
+	}
+	if second := lint(); second != first {
+		t.Errorf("cached run reported:\n%s\nwant:\n%s", second, first)
+	}
+
+	var info bytes.Buffer
+	if code := run([]string{"-cache-dir", cacheDir, "-cache-info"}, &info, &info); code != exitOK {
+		t.Fatalf("-cache-info exit code %d:\n%s", code, info.String())
+	}
+	if !strings.Contains(info.String(), "entries: 2 ") || !strings.Contains(info.String(), "example.com/app findings=1 facts=1 used=") {
+		t.Errorf("-cache-info printed:\n%s", info.String())
+	}
+
+	// Changing the package invalidates its entry
+	fixed := strings.Replace(testmodule.Auth, "w.WriteHeader(http.StatusUnauthorized)\n", "w.WriteHeader(http.StatusUnauthorized)\n\t\t\treturn\n", 1)
+	testmodule.WriteFile(t, module, "app.go", fixed)
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-nextafterreject", "-cache-dir", cacheDir, "./..."}, &stdout, &stderr); code != exitOK {
+		t.Errorf("exit code %d after the fix, want %d:\n%s%s", code, exitOK, stdout.String(), stderr.String())
+	}
+
+	var pruned bytes.Buffer
+	if code := run([]string{"-cache-dir", cacheDir, "-cache-prune", "0"}, &pruned, &pruned); code != exitOK {
+		t.Fatalf("-cache-prune exit code %d:\n%s", code, pruned.String())
+	}
+	if got := pruned.String(); got != "removed 3 cache entries\n" {
+		t.Errorf("-cache-prune printed %q, want 3 removed entries", got)
+	}
+}
+
+// TestCacheFacts checks that a package is analyzed with the facts stored
+// for its unchanged dependencies, which are neither loaded nor analyzed
+func TestCacheFacts(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{
+		"app.go":     watchedApp,
+		"lib/lib.go": "package lib\n\nimport \"net/http\"\n\nfunc Reject(w http.ResponseWriter) {\n\tw.WriteHeader(http.StatusForbidden)\n}\n",
+	})
+	t.Chdir(module)
+	analyzers := []*analysis.Analyzer{analyzer.NextAfterRejectAnalyzer}
+	store, err := openCache(t.TempDir(), analyzers, analyzer.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lint := func(want ...string) {
+		t.Helper()
+		var stderr bytes.Buffer
+		findings, analyzed, err := store.lint([]string{"./..."}, true, analyzers, &stderr)
+		if err != nil || stderr.Len() > 0 {
+			t.Fatalf("lint: %v\n%s", err, stderr.String())
+		}
+		if strings.Join(analyzed, ",") != strings.Join(want, ",") {
+			t.Errorf("analyzed %q, want %q", analyzed, want)
+		}
+		// The finding needs the fact that lib.Reject writes the status
+		if len(findings) != 1 || !strings.Contains(findings[0].Message, "next handler called after") {
+			t.Errorf("findings = %v, want the nextafterreject finding in app.go", findings)
+		}
+	}
+	lint("example.com/app", "example.com/app/lib")
+	lint()
+	testmodule.WriteFile(t, module, "app.go", "// Package app checks credentials\n"+watchedApp)
+	lint("example.com/app")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// changedLines records the lines added or modified by a diff, keyed by the
+// absolute path of the file in its new version.
+type changedLines map[string]map[int]bool
+
+// contains reports whether line of file was changed
+func (c changedLines) contains(file string, line int) bool {
+	return c[file][line]
+}
+
+// containsRange reports whether any line of file in [start, end] was changed
+func (c changedLines) containsRange(file string, start, end int) bool {
+	for line := range c[file] {
+		if line >= start && line <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDiff resolves the -diff flag. An existing file is read as a unified
+// diff; anything else is passed to git diff as a revision range in the
+// repository containing dir. Paths are resolved against the repository root,
+// or against dir outside a repository.
+func loadDiff(spec, dir string) (changedLines, error) {
+	root, gitErr := gitRoot(dir)
+	if gitErr != nil {
+		root = dir
+	}
+
+	if _, err := os.Stat(spec); err == nil {
+		f, err := os.Open(spec)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseUnifiedDiff(f, root)
+	}
+
+	if gitErr != nil {
+		return nil, fmt.Errorf("-diff %s: not a diff file and not inside a git repository", spec)
+	}
+	// --end-of-options keeps a spec starting with a dash from being read as
+	// an option, while still reading it as a revision rather than a path
+	// as a plain -- would
+	cmd := exec.Command("git", "diff", "--no-color", "--no-ext-diff", "--unified=0", "--end-of-options", spec, "--")
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s: %v: %s", spec, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseUnifiedDiff(bytes.NewReader(out), root)
+}
+
+// gitRoot returns the top-level directory of the git repository containing dir
+func gitRoot(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parseUnifiedDiff returns the lines added by a unified diff. A deletion
+// marks the line that now follows it, so removing a return is still
+// attributed to the surrounding code. File headers are only recognized
+// between hunks, whose lines are consumed by the counts of their header,
+// so that a removed "-- x" or an added "++ x" is not taken for one.
+func parseUnifiedDiff(r io.Reader, root string) (changedLines, error) {
+	changed := make(changedLines)
+
+	var (
+		lines map[int]bool // changed lines of the current file, nil if deleted
+		line  int          // line number in the new file of the next hunk line
+		// oldLeft and newLeft count the lines of the current hunk still to
+		// be read from the old and new file; both are 0 between hunks
+		oldLeft, newLeft int
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(text, "+"):
+				if lines != nil {
+					lines[line] = true
+				}
+				line++
+				newLeft--
+			case strings.HasPrefix(text, "-"):
+				if lines != nil {
+					lines[line] = true
+				}
+				oldLeft--
+			case strings.HasPrefix(text, `\`):
+				// "\ No newline at end of file"
+			case strings.HasPrefix(text, " "), text == "":
+				line++
+				oldLeft--
+				newLeft--
+			default:
+				return nil, fmt.Errorf("malformed diff: unexpected line %q in hunk", text)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			name := strings.TrimPrefix(text, "+++ ")
+			if i := strings.IndexByte(name, '\t'); i >= 0 {
+				name = name[:i]
+			}
+			if name == "/dev/null" {
+				lines = nil
+				continue
+			}
+			name = strings.TrimPrefix(name, "b/")
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(root, name)
+			}
+			if changed[name] == nil {
+				changed[name] = make(map[int]bool)
+			}
+			lines = changed[name]
+		case strings.HasPrefix(text, "--- "):
+			// Old file name, the new one follows
+		case strings.HasPrefix(text, "@@ "):
+			start, oldCount, newCount, err := hunkHeader(text)
+			if err != nil {
+				return nil, err
+			}
+			line, oldLeft, newLeft = start, oldCount, newCount
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("malformed diff: truncated hunk")
+	}
+	return changed, nil
+}
+
+// hunkHeader parses a hunk header such as "@@ -12,3 +14,5 @@ func Name() {"
+// into the first line of the new file and the number of lines the hunk
+// holds from the old and new file
+func hunkHeader(header string) (start, oldCount, newCount int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	if _, oldCount, err = hunkRange(fields[1][1:]); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header %q: %v", header, err)
+	}
+	if start, newCount, err = hunkRange(fields[2][1:]); err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed hunk header %q: %v", header, err)
+	}
+	// An empty range names the line before it, as in a pure deletion
+	if newCount == 0 {
+		start++
+	}
+	return start, oldCount, newCount, nil
+}
+
+// hunkRange parses a range such as "14,5" or "14", which counts one line
+func hunkRange(r string) (start, count int, err error) {
+	first, n, hasCount := strings.Cut(r, ",")
+	if start, err = strconv.Atoi(first); err != nil {
+		return 0, 0, err
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(n); err != nil {
+			return 0, 0, err
+		}
+	}
+	if start < 0 || count < 0 {
+		return 0, 0, fmt.Errorf("negative range %q", r)
+	}
+	return start, count, nil
+}
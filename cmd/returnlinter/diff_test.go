@@ -0,0 +1,160 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/api/handler.go b/api/handler.go
+index 1111111..2222222 100644
+--- a/api/handler.go
++++ b/api/handler.go
+@@ -10,2 +10,3 @@ func Auth(next http.Handler) http.Handler {
+ 		if !allowed(r) {
+-			w.WriteHeader(401)
++			w.WriteHeader(http.StatusUnauthorized)
++			log.Println("rejected")
+@@ -30,1 +31,0 @@ func Create(w http.ResponseWriter, r *http.Request) {
+-		return
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package api
+-
+-func old() {}
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,2 @@
++package api
++
+`
+	root := filepath.FromSlash("/repo")
+	changed, err := parseUnifiedDiff(strings.NewReader(diff), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]int{
+		filepath.Join(root, "api/handler.go"): {11, 12, 32},
+		filepath.Join(root, "new.go"):         {1, 2},
+	}
+	if len(changed) != len(want) {
+		t.Errorf("got changes for %d files, want %d: %v", len(changed), len(want), changed)
+	}
+	for file, lines := range want {
+		var got []int
+		for line := range changed[file] {
+			got = append(got, line)
+		}
+		sort.Ints(got)
+		if !equalInts(got, lines) {
+			t.Errorf("%s: changed lines = %v, want %v", file, got, lines)
+		}
+	}
+}
+
+func TestChangedLinesRange(t *testing.T) {
+	changed := changedLines{"/repo/a.go": {12: true}}
+
+	tests := []struct {
+		start, end int
+		want       bool
+	}{
+		{1, 11, false},
+		{1, 12, true},
+		{12, 12, true},
+		{10, 20, true},
+		{13, 20, false},
+	}
+	for _, tt := range tests {
+		if got := changed.containsRange("/repo/a.go", tt.start, tt.end); got != tt.want {
+			t.Errorf("containsRange(%d, %d) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+	if changed.containsRange("/repo/b.go", 1, 100) {
+		t.Error("containsRange reported a change in an untouched file")
+	}
+}
+
+// TestDiffLinesLikeHeaders checks that hunk lines whose content starts
+// like a file header are counted as changes of the current file
+func TestDiffLinesLikeHeaders(t *testing.T) {
+	diff := `--- a/notes.txt
++++ b/notes.txt
+@@ -1,3 +1,3 @@
+ intro
+--- removed
++++ added
+ outro
+@@ -9 +9 @@
+-x
++y
+`
+	root := filepath.FromSlash("/repo")
+	changed, err := parseUnifiedDiff(strings.NewReader(diff), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 {
+		t.Fatalf("got changes for %d files, want 1: %v", len(changed), changed)
+	}
+	var got []int
+	for line := range changed[filepath.Join(root, "notes.txt")] {
+		got = append(got, line)
+	}
+	sort.Ints(got)
+	if want := []int{2, 9}; !equalInts(got, want) {
+		t.Errorf("changed lines = %v, want %v", got, want)
+	}
+
+	if _, err := parseUnifiedDiff(strings.NewReader("+++ b/a.go\n@@ -1,2 +1,2 @@\n x\n"), root); err == nil {
+		t.Error("parseUnifiedDiff accepted a truncated hunk")
+	}
+}
+
+func TestHunkHeader(t *testing.T) {
+	tests := []struct {
+		header                    string
+		start, oldCount, newCount int
+	}{
+		{"@@ -12,3 +14,5 @@ func Name() {", 14, 3, 5},
+		{"@@ -1 +1 @@", 1, 1, 1},
+		{"@@ -30,1 +31,0 @@", 32, 1, 0},
+		{"@@ -0,0 +1,2 @@", 1, 0, 2},
+	}
+	for _, tt := range tests {
+		start, oldCount, newCount, err := hunkHeader(tt.header)
+		if err != nil {
+			t.Errorf("hunkHeader(%q): %v", tt.header, err)
+			continue
+		}
+		if start != tt.start || oldCount != tt.oldCount || newCount != tt.newCount {
+			t.Errorf("hunkHeader(%q) = %d, %d, %d, want %d, %d, %d", tt.header, start, oldCount, newCount, tt.start, tt.oldCount, tt.newCount)
+		}
+	}
+
+	for _, header := range []string{"@@ bogus @@", "@@ -1,x +1 @@", "@@ -1 +1,-2 @@"} {
+		if _, _, _, err := hunkHeader(header); err == nil {
+			t.Errorf("hunkHeader accepted the malformed header %q", header)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+var (
+	// errUncached is returned by lint for packages that it cannot analyze
+	// one by one, which are then analyzed as a whole without the cache
+	errUncached = errors.New("packages cannot be analyzed through the cache")
+	// errPackages is returned by lint once it printed the errors of the
+	// packages it loaded
+	errPackages = errors.New("errors in packages")
+)
+
+// cachedFact is a fact in a cache entry
+type cachedFact struct {
+	// Object is the path of the object the fact is about within its
+	// package, or empty for a fact about the package
+	Object objectpath.Path `json:"object,omitempty"`
+	Type   string          `json:"type"`
+	// Data is the gob encoding of the fact
+	Data []byte `json:"data"`
+}
+
+// exportMode lists packages along with the export data of their
+// dependencies, which typeCheck reads
+const exportMode = metadataMode | packages.NeedExportFile | packages.NeedTypesSizes | packages.NeedModule
+
+// factKey identifies a fact exported by a package
+type factKey struct {
+	object objectpath.Path
+	typ    reflect.Type
+}
+
+// lint runs analyzers on the packages matching patterns and returns their
+// findings, along with the IDs of the packages it analyzed. It is the
+// cached counterpart of checker.Analyze, driving the analysis package by
+// package like go vet does: a package whose entry is found is neither
+// loaded nor analyzed, and the facts stored in the entry stand in for its
+// analysis when a package importing it is. Dependencies outside the
+// patterns are analyzed for their facts only, and only if they import
+// net/http, since no other package exports any. lint returns errUncached
+// when the metadata of the packages has errors or the patterns name files.
+func (c *cache) lint(patterns []string, tests bool, analyzers []*analysis.Analyzer, stderr io.Writer) ([]finding, []string, error) {
+	meta, err := packages.Load(&packages.Config{Mode: metadataMode, Tests: tests}, patterns...)
+	if err != nil {
+		return nil, nil, err
+	}
+	roots := make(map[string]bool)
+	for _, pkg := range meta {
+		if len(pkg.Errors) > 0 || len(pkg.GoFiles) == 0 || pkg.PkgPath == "command-line-arguments" {
+			return nil, nil, errUncached
+		}
+		// Generated test mains declare no handlers
+		if !isTestMain(pkg) {
+			roots[pkg.ID] = true
+		}
+	}
+	keys := c.keys(meta)
+
+	// Dependencies only run the analyzers exporting facts
+	var factAnalyzers []*analysis.Analyzer
+	factTypes := make(map[string]reflect.Type)
+	seen := make(map[*analysis.Analyzer]bool)
+	var require func(a *analysis.Analyzer)
+	require = func(a *analysis.Analyzer) {
+		if seen[a] {
+			return
+		}
+		seen[a] = true
+		for _, req := range a.Requires {
+			require(req)
+		}
+		if len(a.FactTypes) > 0 {
+			factAnalyzers = append(factAnalyzers, a)
+		}
+		for _, fact := range a.FactTypes {
+			factTypes[fmt.Sprintf("%T", fact)] = reflect.TypeOf(fact)
+		}
+	}
+	for _, a := range analyzers {
+		require(a)
+	}
+
+	// The import graph, dependencies first
+	var order []*packages.Package
+	packages.Visit(meta, nil, func(pkg *packages.Package) {
+		if !isTestMain(pkg) {
+			order = append(order, pkg)
+		}
+	})
+
+	var findings []finding
+	facts := make(map[string]map[factKey]analysis.Fact)
+	missed := make(map[string]bool)
+	for _, pkg := range order {
+		if entry := c.get(keys[pkg.ID]); entry != nil && (entry.Root || !roots[pkg.ID]) {
+			if decoded, err := decodeFacts(entry.Facts, factTypes); err == nil {
+				facts[pkg.ID] = decoded
+				if roots[pkg.ID] {
+					findings = append(findings, entry.Findings...)
+				}
+				continue
+			}
+		}
+		if roots[pkg.ID] {
+			missed[pkg.ID] = true
+		}
+	}
+	// Dependencies without an entry are analyzed only for the packages
+	// that are
+	todo := make(map[string]bool)
+	visited := make(map[string]bool)
+	var need func(pkg *packages.Package)
+	need = func(pkg *packages.Package) {
+		for _, imp := range pkg.Imports {
+			if visited[imp.ID] {
+				continue
+			}
+			visited[imp.ID] = true
+			if _, ok := facts[imp.ID]; !ok && !roots[imp.ID] {
+				if _, ok := imp.Imports["net/http"]; ok {
+					todo[imp.ID] = true
+				}
+			}
+			need(imp)
+		}
+	}
+	for _, pkg := range order {
+		if missed[pkg.ID] {
+			todo[pkg.ID] = true
+			need(pkg)
+		}
+	}
+	if len(todo) == 0 {
+		return findings, nil, nil
+	}
+
+	// The packages are listed again along with the export data of every
+	// package in the import graph, which go list compiles, reusing the
+	// build cache. Each package to analyze is type-checked from source
+	// against the export data of its imports.
+	exported, err := packages.Load(&packages.Config{Mode: exportMode, Tests: tests}, patterns...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if packages.PrintErrors(exported) > 0 {
+		return nil, nil, errPackages
+	}
+	fset := token.NewFileSet()
+	loaded := make(map[string]*packages.Package)
+	packages.Visit(exported, nil, func(pkg *packages.Package) {
+		if todo[pkg.ID] {
+			loaded[pkg.ID] = pkg
+		}
+	})
+	if len(loaded) != len(todo) {
+		return nil, nil, errUncached
+	}
+
+	// Each package is analyzed once the packages it imports, directly or
+	// not, are done
+	var (
+		mu       sync.Mutex
+		analyzed []string
+		failed   = make(map[string]bool)
+		done     = make(map[string]chan struct{})
+		wg       sync.WaitGroup
+		limit    = make(chan struct{}, runtime.GOMAXPROCS(0))
+	)
+	for _, pkg := range order {
+		done[pkg.ID] = make(chan struct{})
+	}
+	for _, pkg := range order {
+		wg.Add(1)
+		go func(pkg *packages.Package) {
+			defer wg.Done()
+			defer close(done[pkg.ID])
+			var broken []string
+			for _, imp := range pkg.Imports {
+				<-done[imp.ID]
+				mu.Lock()
+				if failed[imp.ID] {
+					broken = append(broken, imp.ID)
+				}
+				mu.Unlock()
+			}
+			src := loaded[pkg.ID]
+			if src == nil {
+				if len(broken) > 0 {
+					mu.Lock()
+					failed[pkg.ID] = true
+					mu.Unlock()
+				}
+				return
+			}
+
+			var (
+				diagnostics []analysis.Diagnostic
+				exported    map[factKey]analysis.Fact
+				err         error
+			)
+			if len(broken) > 0 {
+				sort.Strings(broken)
+				err = fmt.Errorf("failed prerequisites: %s", strings.Join(broken, ", "))
+			} else {
+				limit <- struct{}{}
+				run := factAnalyzers
+				if roots[pkg.ID] {
+					run = analyzers
+				}
+				imported := importedFacts(pkg, func(id string) map[factKey]analysis.Fact {
+					mu.Lock()
+					defer mu.Unlock()
+					return facts[id]
+				})
+				if src, err = typeCheck(fset, src); err == nil {
+					diagnostics, exported, err = analyzePackage(src, run, imported)
+				}
+				<-limit
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			analyzed = append(analyzed, pkg.ID)
+			if err != nil {
+				failed[pkg.ID] = true
+				fmt.Fprintf(stderr, "returnlinter: %s: %v\n", pkg.ID, err)
+				return
+			}
+			facts[pkg.ID] = exported
+
+			entry := &cacheEntry{Package: pkg.ID, Created: time.Now(), Root: roots[pkg.ID]}
+			for _, diag := range diagnostics {
+				entry.Findings = append(entry.Findings, newFinding(src, diag))
+			}
+			findings = append(findings, entry.Findings...)
+			if keys[pkg.ID] == "" {
+				return
+			}
+			if entry.Facts, err = encodeFacts(exported); err == nil {
+				err = c.put(keys[pkg.ID], entry)
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "returnlinter: writing cache: %v\n", err)
+			}
+		}(pkg)
+	}
+	wg.Wait()
+	sort.Strings(analyzed)
+	return findings, analyzed, nil
+}
+
+// importedFacts returns the function looking up the facts exported by a
+// package that pkg imports, directly or not, by package path. facts returns
+// the facts of a package by ID.
+func importedFacts(pkg *packages.Package, facts func(id string) map[factKey]analysis.Fact) func(path string) map[factKey]analysis.Fact {
+	ids := make(map[string]string)
+	packages.Visit([]*packages.Package{pkg}, nil, func(dep *packages.Package) {
+		ids[dep.PkgPath] = dep.ID
+	})
+	return func(path string) map[factKey]analysis.Fact {
+		if id, ok := ids[path]; ok && id != pkg.ID {
+			return facts(id)
+		}
+		return nil
+	}
+}
+
+// typeCheck parses pkg and type-checks it against the export data of its
+// imports, the way go vet does, and returns it with its syntax and types
+func typeCheck(fset *token.FileSet, pkg *packages.Package) (*packages.Package, error) {
+	var files []*ast.File
+	for _, name := range pkg.CompiledGoFiles {
+		file, err := parser.ParseFile(fset, name, nil, parser.AllErrors|parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	// The import paths in the files are resolved to package paths, which
+	// the export data refers to packages by
+	exports := make(map[string]string)
+	packages.Visit([]*packages.Package{pkg}, nil, func(dep *packages.Package) {
+		exports[dep.PkgPath] = dep.ExportFile
+	})
+	gc := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		if file := exports[path]; file != "" {
+			return os.Open(file)
+		}
+		return nil, fmt.Errorf("no export data for %s", path)
+	})
+	checked := *pkg
+	conf := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			imp := pkg.Imports[path]
+			if imp == nil {
+				return nil, fmt.Errorf("%s does not import %s", pkg.ID, path)
+			}
+			return gc.Import(imp.PkgPath)
+		}),
+		Sizes: pkg.TypesSizes,
+		Error: func(err error) {
+			if err, ok := err.(types.Error); ok {
+				checked.TypeErrors = append(checked.TypeErrors, err)
+			}
+		},
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		conf.GoVersion = "go" + pkg.Module.GoVersion
+	}
+	info := &types.Info{
+		Types:        make(map[ast.Expr]types.TypeAndValue),
+		Defs:         make(map[*ast.Ident]types.Object),
+		Uses:         make(map[*ast.Ident]types.Object),
+		Implicits:    make(map[ast.Node]types.Object),
+		Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		Instances:    make(map[*ast.Ident]types.Instance),
+		FileVersions: make(map[*ast.File]string),
+	}
+	checked.Types, _ = conf.Check(pkg.PkgPath, fset, files, info)
+	checked.Fset = fset
+	checked.Syntax = files
+	checked.TypesInfo = info
+	checked.IllTyped = len(checked.TypeErrors) > 0
+	return &checked, nil
+}
+
+// importerFunc implements types.Importer
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// analyzePackage runs analyzers, along with the analyzers they require, on
+// pkg, which was type-checked from source. It returns the diagnostics of
+// analyzers and the facts exported about pkg and its objects. imported
+// returns the facts exported by a dependency, by package path.
+func analyzePackage(pkg *packages.Package, analyzers []*analysis.Analyzer, imported func(path string) map[factKey]analysis.Fact) ([]analysis.Diagnostic, map[factKey]analysis.Fact, error) {
+	type objectFactKey struct {
+		obj types.Object
+		typ reflect.Type
+	}
+	objectFacts := make(map[objectFactKey]analysis.Fact)
+	packageFacts := make(map[reflect.Type]analysis.Fact)
+
+	// The packages that facts can be about, by path
+	deps := make(map[string]*types.Package)
+	var walk func(p *types.Package)
+	walk = func(p *types.Package) {
+		if _, ok := deps[p.Path()]; ok {
+			return
+		}
+		deps[p.Path()] = p
+		for _, imp := range p.Imports() {
+			walk(imp)
+		}
+	}
+	walk(pkg.Types)
+
+	requested := make(map[*analysis.Analyzer]bool)
+	for _, a := range analyzers {
+		requested[a] = true
+	}
+	results := make(map[*analysis.Analyzer]interface{})
+	errs := make(map[*analysis.Analyzer]error)
+	var diagnostics []analysis.Diagnostic
+
+	var exec func(a *analysis.Analyzer) error
+	exec = func(a *analysis.Analyzer) error {
+		if err, ok := errs[a]; ok {
+			return err
+		}
+		err := func() error {
+			inputs := make(map[*analysis.Analyzer]interface{})
+			for _, req := range a.Requires {
+				if err := exec(req); err != nil {
+					return err
+				}
+				inputs[req] = results[req]
+			}
+			if pkg.IllTyped && !a.RunDespiteErrors {
+				return fmt.Errorf("analysis skipped due to errors in package")
+			}
+
+			owned := make(map[reflect.Type]bool)
+			for _, fact := range a.FactTypes {
+				owned[reflect.TypeOf(fact)] = true
+			}
+			pass := &analysis.Pass{
+				Analyzer:     a,
+				Fset:         pkg.Fset,
+				Files:        pkg.Syntax,
+				OtherFiles:   pkg.OtherFiles,
+				IgnoredFiles: pkg.IgnoredFiles,
+				Pkg:          pkg.Types,
+				TypesInfo:    pkg.TypesInfo,
+				TypesSizes:   pkg.TypesSizes,
+				TypeErrors:   pkg.TypeErrors,
+				Module:       &analysis.Module{},
+				ResultOf:     inputs,
+				ReadFile:     os.ReadFile,
+				Report: func(d analysis.Diagnostic) {
+					if requested[a] {
+						diagnostics = append(diagnostics, d)
+					}
+				},
+				ImportObjectFact: func(obj types.Object, ptr analysis.Fact) bool {
+					typ := reflect.TypeOf(ptr)
+					fact, ok := objectFacts[objectFactKey{obj, typ}]
+					if !ok && obj.Pkg() != nil && obj.Pkg() != pkg.Types {
+						if path, err := objectpath.For(obj); err == nil {
+							fact, ok = imported(obj.Pkg().Path())[factKey{path, typ}]
+						}
+					}
+					if ok {
+						reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(fact).Elem())
+					}
+					return ok
+				},
+				ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+					if obj.Pkg() != pkg.Types {
+						panic(fmt.Sprintf("%s: fact exported about %s of another package", a.Name, obj))
+					}
+					objectFacts[objectFactKey{obj, reflect.TypeOf(fact)}] = fact
+				},
+				ImportPackageFact: func(p *types.Package, ptr analysis.Fact) bool {
+					typ := reflect.TypeOf(ptr)
+					fact, ok := packageFacts[typ]
+					if p != pkg.Types {
+						fact, ok = imported(p.Path())[factKey{"", typ}]
+					}
+					if ok {
+						reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(fact).Elem())
+					}
+					return ok
+				},
+				ExportPackageFact: func(fact analysis.Fact) {
+					packageFacts[reflect.TypeOf(fact)] = fact
+				},
+				AllObjectFacts: func() []analysis.ObjectFact {
+					var all []analysis.ObjectFact
+					for key, fact := range objectFacts {
+						if owned[key.typ] {
+							all = append(all, analysis.ObjectFact{Object: key.obj, Fact: fact})
+						}
+					}
+					for path, dep := range deps {
+						for key, fact := range imported(path) {
+							if key.object == "" || !owned[key.typ] {
+								continue
+							}
+							if obj, err := objectpath.Object(dep, key.object); err == nil {
+								all = append(all, analysis.ObjectFact{Object: obj, Fact: fact})
+							}
+						}
+					}
+					return all
+				},
+				AllPackageFacts: func() []analysis.PackageFact {
+					var all []analysis.PackageFact
+					for typ, fact := range packageFacts {
+						if owned[typ] {
+							all = append(all, analysis.PackageFact{Package: pkg.Types, Fact: fact})
+						}
+					}
+					for path, dep := range deps {
+						for key, fact := range imported(path) {
+							if key.object == "" && owned[key.typ] {
+								all = append(all, analysis.PackageFact{Package: dep, Fact: fact})
+							}
+						}
+					}
+					return all
+				},
+			}
+			result, err := a.Run(pass)
+			if err != nil {
+				return err
+			}
+			results[a] = result
+			return nil
+		}()
+		errs[a] = err
+		return err
+	}
+	for _, a := range analyzers {
+		if err := exec(a); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Facts about objects that other packages cannot refer to are dropped
+	exported := make(map[factKey]analysis.Fact)
+	for key, fact := range objectFacts {
+		if path, err := objectpath.For(key.obj); err == nil {
+			exported[factKey{path, key.typ}] = fact
+		}
+	}
+	for typ, fact := range packageFacts {
+		exported[factKey{"", typ}] = fact
+	}
+	return diagnostics, exported, nil
+}
+
+// encodeFacts returns facts in the form stored in cache entries, sorted
+func encodeFacts(facts map[factKey]analysis.Fact) ([]cachedFact, error) {
+	var encoded []cachedFact
+	for key, fact := range facts {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(fact); err != nil {
+			return nil, fmt.Errorf("encoding %T fact: %v", fact, err)
+		}
+		encoded = append(encoded, cachedFact{Object: key.object, Type: fmt.Sprintf("%T", fact), Data: buf.Bytes()})
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		if encoded[i].Object != encoded[j].Object {
+			return encoded[i].Object < encoded[j].Object
+		}
+		return encoded[i].Type < encoded[j].Type
+	})
+	return encoded, nil
+}
+
+// decodeFacts returns the facts of a cache entry. factTypes holds the
+// types of the facts of the analyzers that run, by name.
+func decodeFacts(encoded []cachedFact, factTypes map[string]reflect.Type) (map[factKey]analysis.Fact, error) {
+	facts := make(map[factKey]analysis.Fact)
+	for _, f := range encoded {
+		typ, ok := factTypes[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown fact type %s", f.Type)
+		}
+		fact := reflect.New(typ.Elem()).Interface().(analysis.Fact)
+		if err := gob.NewDecoder(bytes.NewReader(f.Data)).Decode(fact); err != nil {
+			return nil, fmt.Errorf("decoding %s fact: %v", f.Type, err)
+		}
+		facts[factKey{f.Object, typ}] = fact
+	}
+	return facts, nil
+}
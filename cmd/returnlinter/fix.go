@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"os"
+	"sort"
+
+	"golang.org/x/tools/go/analysis/checker"
+)
+
+// edit is a replacement of the bytes [start, end) of a file
+type edit struct {
+	start, end int
+	text       string
+}
+
+// applyFixes implements -fix like the other go/analysis drivers: the first
+// suggested fix of every diagnostic left on the root actions of graph is
+// applied, except in generated files, and each changed file is formatted
+// and written back. Identical edits reported for a package and its test
+// variant are applied once; overlapping ones are an error and no file is
+// written. It returns the names of the files written.
+func applyFixes(graph *checker.Graph) ([]string, error) {
+	edits := make(map[string][]edit)
+	generated := make(map[string]bool)
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			continue
+		}
+		for _, file := range act.Package.Syntax {
+			generated[act.Package.Fset.File(file.FileStart).Name()] = ast.IsGenerated(file)
+		}
+		for _, diag := range act.Diagnostics {
+			if len(diag.SuggestedFixes) == 0 {
+				continue
+			}
+			for _, e := range diag.SuggestedFixes[0].TextEdits {
+				end := e.End
+				if !end.IsValid() {
+					end = e.Pos
+				}
+				tokFile := act.Package.Fset.File(e.Pos)
+				if tokFile == nil || act.Package.Fset.File(end) != tokFile {
+					return nil, fmt.Errorf("%s: fix %q: invalid edit position", act, diag.SuggestedFixes[0].Message)
+				}
+				name := tokFile.Name()
+				if generated[name] {
+					continue
+				}
+				edits[name] = append(edits[name], edit{tokFile.Offset(e.Pos), tokFile.Offset(end), string(e.NewText)})
+			}
+		}
+	}
+
+	out := make(map[string][]byte)
+	for name, fileEdits := range edits {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		fixed, err := applyEdits(src, fileEdits)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if formatted, err := format.Source(fixed); err == nil {
+			fixed = formatted
+		}
+		out[name] = fixed
+	}
+
+	var written []string
+	for name, content := range out {
+		info, err := os.Stat(name)
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(name, content, info.Mode().Perm()); err != nil {
+			return written, err
+		}
+		written = append(written, name)
+	}
+	sort.Strings(written)
+	return written, nil
+}
+
+// applyEdits returns src with edits applied, after dropping duplicates
+func applyEdits(src []byte, edits []edit) ([]byte, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+
+	var buf bytes.Buffer
+	last := 0
+	for i, e := range edits {
+		if i > 0 && e == edits[i-1] {
+			continue
+		}
+		if e.start < last || e.end < e.start || e.end > len(src) {
+			return nil, fmt.Errorf("conflicting fixes at offset %d", e.start)
+		}
+		buf.Write(src[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// filterGraph drops the diagnostics of the root actions of graph that lie
+// outside the changed lines, so that -fix, -json and -c see the same
+// findings as the plain text output.
+func filterGraph(graph *checker.Graph, changed changedLines) {
+	if changed == nil {
+		return
+	}
+	for _, act := range graph.Roots {
+		kept := act.Diagnostics[:0]
+		for _, diag := range act.Diagnostics {
+			if newFinding(act.Package, diag).inDiff(changed) {
+				kept = append(kept, diag)
+			}
+		}
+		act.Diagnostics = kept
+	}
+}
@@ -1,10 +1,490 @@
+// Command returnlinter runs the returnlinter rules over Go packages.
+//
+// Run on its own, it loads the packages named on the command line and
+// reports the findings of every enabled rule. Invoked by go vet through
+// -vettool, it speaks the vet tool protocol instead.
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
-	"golang.org/x/tools/go/analysis/singlechecker"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/analysis/unitchecker"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Exit codes, matching the other go/analysis drivers
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitFindings = 3
 )
 
 func main() {
-	singlechecker.Main(analyzer.Analyzer)
+	if isVetInvocation(os.Args[1:]) {
+		unitchecker.Main(vetAnalyzers(os.Args[1:])...)
+	}
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// isVetInvocation reports whether the command was started by go vet, which
+// first queries -V=full and -flags and then passes the selected flags
+// followed by a .cfg file for each package.
+func isVetInvocation(args []string) bool {
+	if len(args) > 0 && strings.HasSuffix(args[len(args)-1], ".cfg") {
+		return true
+	}
+	for _, arg := range args {
+		switch arg {
+		case "-V=full", "--V=full", "-flags", "--flags":
+			return true
+		}
+	}
+	return false
+}
+
+// vetAnalyzers returns the analyzers for a go vet invocation. The queries
+// list every rule, so that go vet accepts their flags; a package is checked
+// with the optional rules left out unless their flag is passed.
+func vetAnalyzers(args []string) []*analysis.Analyzer {
+	if len(args) == 0 || !strings.HasSuffix(args[len(args)-1], ".cfg") {
+		return analyzer.Analyzers
+	}
+	var analyzers []*analysis.Analyzer
+	for i, a := range analyzer.Analyzers {
+		if !analyzer.Rules[i].Optional || hasFlag(args, a.Name) {
+			analyzers = append(analyzers, a)
+		}
+	}
+	return analyzers
+}
+
+// hasFlag reports whether args set the flag name, to any value
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		arg = strings.TrimLeft(arg, "-")
+		if arg == name || strings.HasPrefix(arg, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// run is the standalone driver. It returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("returnlinter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: returnlinter [flags] packages...\n\n")
+		fmt.Fprintf(stderr, "Runs every rule but the optional ones and those disabled with -<rule>=false, unless one or more rules are enabled explicitly.\n\n")
+		flags.PrintDefaults()
+	}
+
+	enabled := make([]*bool, len(analyzer.Analyzers))
+	for i, a := range analyzer.Analyzers {
+		usage := "enable " + a.Name + " analysis"
+		if analyzer.Rules[i].Optional {
+			usage += " (optional, off unless enabled)"
+		}
+		enabled[i] = flags.Bool(a.Name, false, usage)
+	}
+	var configFlags analyzer.ConfigFlags
+	configFlags.Register(flags)
+	diffSpec := flags.String("diff", "", "only report findings on lines changed by this unified diff file or git revision range (e.g. main...HEAD)")
+	tests := flags.Bool("test", true, "also analyze test packages")
+	reportFormat := flags.String("report", "text", "output format: text, or json for a per-handler summary")
+	fix := flags.Bool("fix", false, "apply all suggested fixes")
+	jsonOutput := flags.Bool("json", false, "emit JSON output")
+	contextLines := flags.Int("c", -1, "display offending line with this many lines of context")
+	useCache := flags.Bool("cache", true, "reuse the findings of unchanged packages from previous runs")
+	cacheDir := flags.String("cache-dir", defaultCacheDir(), "cache directory")
+	cacheInfo := flags.Bool("cache-info", false, "list the cache entries and exit")
+	cachePrune := flags.Duration("cache-prune", 0, "remove cache entries unused for this long (0 removes all) and exit")
+	watch := flags.Bool("watch", false, "keep running, re-analyze packages when their .go files change and print the findings added and resolved")
+	watchInterval := flags.Duration("watch-interval", 500*time.Millisecond, "how often -watch polls for changes")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	pruning := false
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		pruning = pruning || f.Name == "cache-prune"
+		set[f.Name] = true
+	})
+	if *cacheInfo || pruning {
+		return maintainCache(*cacheDir, *cacheInfo, pruning, *cachePrune, stdout, stderr)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+	if *reportFormat != "text" && *reportFormat != "json" {
+		fmt.Fprintf(stderr, "-report: unknown format %q, want text or json\n", *reportFormat)
+		return exitError
+	}
+	if *fix || *jsonOutput || *contextLines >= 0 {
+		if *reportFormat != "text" {
+			fmt.Fprintln(stderr, "-report=json cannot be combined with -fix, -json or -c")
+			return exitError
+		}
+		if *fix && *jsonOutput {
+			fmt.Fprintln(stderr, "-fix cannot be combined with -json")
+			return exitError
+		}
+	}
+	if *watch && (*reportFormat != "text" || *fix || *jsonOutput || *contextLines >= 0) {
+		fmt.Fprintln(stderr, "-watch only supports the text report")
+		return exitError
+	}
+	if *watchInterval <= 0 {
+		fmt.Fprintln(stderr, "-watch-interval must be positive")
+		return exitError
+	}
+
+	cfg, err := configFlags.Config()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	linter, err := analyzer.New(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	// As with multichecker, rules enabled explicitly run on their own;
+	// otherwise every rule runs but the optional ones and those disabled
+	// explicitly
+	var analyzers []*analysis.Analyzer
+	enabledRules := make(map[*analyzer.Rule]bool)
+	for i, a := range linter.Analyzers {
+		if *enabled[i] {
+			analyzers = append(analyzers, a)
+			enabledRules[analyzer.Rules[i]] = true
+		}
+	}
+	if len(analyzers) == 0 {
+		for i, a := range linter.Analyzers {
+			if rule := analyzer.Rules[i]; !rule.Optional && !set[a.Name] {
+				analyzers = append(analyzers, a)
+				enabledRules[rule] = true
+			}
+		}
+	}
+
+	var changed changedLines
+	if *diffSpec != "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		changed, err = loadDiff(*diffSpec, wd)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		w := &watcher{
+			analyzers: analyzers,
+			tests:     *tests,
+			changed:   changed,
+			interval:  *watchInterval,
+			stdout:    stdout,
+			stderr:    stderr,
+		}
+		return w.watch(ctx, flags.Args())
+	}
+
+	// The JSON report needs the Engine result of every package, and -fix,
+	// -json and -c the diagnostics themselves, none of which are cached
+	plain := *reportFormat == "text" && !*fix && !*jsonOutput && *contextLines < 0
+	var store *cache
+	if *useCache && plain {
+		var err error
+		if store, err = openCache(*cacheDir, analyzers, cfg); err != nil {
+			fmt.Fprintf(stderr, "returnlinter: cache disabled: %v\n", err)
+		}
+	}
+
+	var findings []finding
+	if store != nil {
+		var err error
+		findings, _, err = store.lint(flags.Args(), *tests, analyzers, stderr)
+		switch err {
+		case nil:
+		case errUncached:
+			store = nil
+		case errPackages:
+			return exitError
+		default:
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	if store == nil {
+		pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax, Tests: *tests}, flags.Args()...)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		if packages.PrintErrors(pkgs) > 0 {
+			return exitError
+		}
+
+		roots := analyzers
+		if *reportFormat == "json" {
+			// The checker only keeps the results of root actions
+			roots = append(roots[:len(roots):len(roots)], linter.Engine)
+		}
+		graph, err := checker.Analyze(roots, pkgs, nil)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+
+		if *reportFormat == "json" {
+			report := buildReport(graph, linter.Engine, enabledRules, changed)
+			if err := writeJSON(stdout, report); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+			if report.Summary.Violations > 0 {
+				return exitFindings
+			}
+			return exitOK
+		}
+		if !plain {
+			filterGraph(graph, changed)
+			return printGraph(graph, *fix, *jsonOutput, *contextLines, stdout, stderr)
+		}
+		for _, pkgFindings := range findingsOf(graph) {
+			findings = append(findings, pkgFindings...)
+		}
+	}
+
+	findings = collect(findings, changed)
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "%s: %s\n", f.Posn, f.Message)
+	}
+	if len(findings) > 0 {
+		return exitFindings
+	}
+	return exitOK
+}
+
+// printGraph implements -fix, -json and -c on the root actions of graph,
+// with the same exit codes as the other go/analysis drivers: -fix and
+// -json exit with exitOK despite findings.
+func printGraph(graph *checker.Graph, fix, jsonOutput bool, contextLines int, stdout, stderr io.Writer) int {
+	if fix {
+		written, err := applyFixes(graph)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		for _, name := range written {
+			fmt.Fprintf(stderr, "returnlinter: fixed %s\n", name)
+		}
+		return exitOK
+	}
+	if jsonOutput {
+		if err := graph.PrintJSON(stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	if err := graph.PrintText(stdout, contextLines); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	for _, act := range graph.Roots {
+		if len(act.Diagnostics) > 0 {
+			return exitFindings
+		}
+	}
+	return exitOK
+}
+
+// dedupe returns the distinct strings of list, in order
+func dedupe(list []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// maintainCache implements -cache-info and -cache-prune
+func maintainCache(dir string, info, prune bool, age time.Duration, stdout, stderr io.Writer) int {
+	if dir == "" {
+		fmt.Fprintln(stderr, "no cache directory: set $XDG_CACHE_HOME or -cache-dir")
+		return exitError
+	}
+	c := &cache{dir: dir}
+	if prune {
+		removed, err := c.prune(age)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		fmt.Fprintf(stdout, "removed %d cache entries\n", removed)
+	}
+	if info {
+		if err := c.info(stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+	return exitOK
+}
+
+// finding is a diagnostic ready to be filtered, printed and cached
+type finding struct {
+	Posn     token.Position `json:"position"`
+	Category string         `json:"category"`
+	Message  string         `json:"message"`
+	// Handler is the range of lines of the handler enclosing the finding,
+	// if there is one, for -diff
+	Handler *lineRange `json:"handler,omitempty"`
+}
+
+type lineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func newFinding(pkg *packages.Package, diag analysis.Diagnostic) finding {
+	f := finding{
+		Posn:     pkg.Fset.Position(diag.Pos),
+		Category: diag.Category,
+		Message:  diag.Message,
+	}
+	if fn := enclosingHandler(pkg, diag.Pos); fn != nil {
+		f.Handler = &lineRange{
+			Start: pkg.Fset.Position(fn.Pos()).Line,
+			End:   pkg.Fset.Position(fn.End()).Line,
+		}
+	}
+	return f
+}
+
+// findingsOf returns the findings of the root actions of graph by package
+func findingsOf(graph *checker.Graph) map[*packages.Package][]finding {
+	findings := make(map[*packages.Package][]finding)
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			continue
+		}
+		for _, diag := range act.Diagnostics {
+			findings[act.Package] = append(findings[act.Package], newFinding(act.Package, diag))
+		}
+	}
+	return findings
+}
+
+// collect sorts findings by position, dropping duplicates reported for a
+// package and its test variant and, when changed is not nil, findings
+// outside the changed lines.
+func collect(findings []finding, changed changedLines) []finding {
+	type key struct {
+		posn     token.Position
+		category string
+		message  string
+	}
+	seen := make(map[key]bool)
+
+	var out []finding
+	for _, f := range findings {
+		k := key{f.Posn, f.Category, f.Message}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		if changed != nil && !f.inDiff(changed) {
+			continue
+		}
+		out = append(out, f)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return lessPosition(out[i].Posn, out[j].Posn)
+	})
+	return out
+}
+
+// lessPosition orders positions by file, line and column
+func lessPosition(a, b token.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// inDiff reports whether a finding should be kept in diff mode: either its
+// own line was changed, or a line of the handler enclosing it was.
+func (f finding) inDiff(changed changedLines) bool {
+	filename, err := filepath.Abs(f.Posn.Filename)
+	if err != nil {
+		return false
+	}
+	if changed.contains(filename, f.Posn.Line) {
+		return true
+	}
+	return f.Handler != nil && changed.containsRange(filename, f.Handler.Start, f.Handler.End)
+}
+
+// enclosingHandler returns the innermost function declaration or literal
+// around pos that takes an http.ResponseWriter.
+func enclosingHandler(pkg *packages.Package, pos token.Pos) ast.Node {
+	for _, file := range pkg.Syntax {
+		if pos < file.Pos() || pos >= file.End() {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+		for _, n := range path {
+			var params *ast.FieldList
+			switch fn := n.(type) {
+			case *ast.FuncDecl:
+				params = fn.Type.Params
+			case *ast.FuncLit:
+				params = fn.Type.Params
+			default:
+				continue
+			}
+			for _, field := range params.List {
+				if t := pkg.TypesInfo.TypeOf(field.Type); t != nil && t.String() == "net/http.ResponseWriter" {
+					return n
+				}
+			}
+		}
+	}
+	return nil
 }
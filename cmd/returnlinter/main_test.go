@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/internal/testmodule"
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+)
+
+func TestJSONReport(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-report=json", "-nextafterreject", "../../testdata/src/handlers"}, &stdout, &stderr)
+	if code != exitFindings {
+		t.Fatalf("exit code %d, want %d; stderr:\n%s", code, exitFindings, stderr.String())
+	}
+
+	var report jsonReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON report: %v\n%s", err, stdout.String())
+	}
+
+	summary := report.Summary
+	if summary.Handlers != 3 || summary.Checked != 3 || summary.Violations != 1 || summary.Suppressed != 1 {
+		t.Errorf("summary = %+v, want 3 handlers, 3 checked, 1 violation and 1 suppressed finding", summary)
+	}
+	for _, kind := range []string{"middleware", "handler", "ServeHTTP"} {
+		if summary.Kinds[kind] != 1 {
+			t.Errorf("summary has %d %s handlers, want 1", summary.Kinds[kind], kind)
+		}
+	}
+
+	auth := report.Handlers[0]
+	if auth.Name != "Auth$1" || auth.Kind != "middleware" || auth.StatusWrites != 1 {
+		t.Fatalf("first handler = %+v, want the Auth middleware", auth)
+	}
+	if len(auth.Violations) != 1 || auth.Violations[0].Rule != "RL007" {
+		t.Errorf("Auth violations = %+v, want one RL007 finding", auth.Violations)
+	}
+	if health := report.Handlers[1]; len(health.Routes) != 1 || health.Routes[0] != "GET /health" {
+		t.Errorf("Health routes = %q, want [GET /health]", health.Routes)
+	}
+	if len(auth.Suppressed) != 1 || auth.Suppressed[0].Rule != "RL001" || auth.Suppressed[0].Reason != suppressedRuleDisabled {
+		t.Errorf("Auth suppressed findings = %+v, want RL001 with the rule disabled", auth.Suppressed)
+	}
+}
+
+// TestConfigFlags checks that -loggers, -response-helpers,
+// -uncheckedwrite-error-status and -status-policies reach the rules
+// and are part of the cache key
+func TestConfigFlags(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{
+		"app.go": `package app
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+type Responder interface {
+	Reject(w http.ResponseWriter, code int)
+}
+
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			slog.Warn("unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func Limit(resp Responder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > 1<<20 {
+			resp.Reject(w, http.StatusRequestEntityTooLarge)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("method not allowed"))
+		return
+	}
+	w.Write([]byte("ok"))
+}
+`,
+		"policies.json": `[{"name": "no-405", "functions": ["Health"], "deny": ["405"]}]`,
+		"typo.json":     `[{"name": "typo", "deny": ["405"], "function": ["Health"]}]`,
+	})
+	t.Chdir(module)
+	cacheDir := t.TempDir()
+
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"-returnafterstatus"}, exitFindings, "app.go:15:4: WriteHeader call not immediately followed by return statement\n"},
+		{[]string{"-returnafterstatus", "-loggers", "log/slog"}, exitOK, ""},
+		{[]string{"-nextafterreject"}, exitOK, ""},
+		{[]string{"-nextafterreject", "-response-helpers", "(example.com/app.Responder).Reject"}, exitFindings, "app.go:28:3: next handler called after the response status was already written\n"},
+		{[]string{"-uncheckedwrite"}, exitFindings, "app.go:35:3: error writing the response with Write is not checked\napp.go:38:2: error writing the response with Write is not checked\n"},
+		{[]string{"-uncheckedwrite", "-uncheckedwrite-error-status"}, exitFindings, "app.go:35:3: error writing the response with Write is not checked\n"},
+		{[]string{"-statuspolicy"}, exitOK, ""},
+		{[]string{"-statuspolicy", "-status-policies", "policies.json"}, exitFindings, "app.go:34:3: status 405 violates policy \"no-405\"\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append(tt.args, "-cache-dir", cacheDir, "./...")
+		if code := run(args, &stdout, &stderr); code != tt.code {
+			t.Errorf("%q: exit code %d, want %d; stderr:\n%s", tt.args, code, tt.code, stderr.String())
+		}
+		if got := strings.ReplaceAll(stdout.String(), module+string(filepath.Separator), ""); got != tt.want {
+			t.Errorf("%q reported:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	var stderr bytes.Buffer
+	if code := run([]string{"-response-helpers", "Reject", "./..."}, io.Discard, &stderr); code != exitError || !strings.Contains(stderr.String(), `response helper "Reject"`) {
+		t.Errorf("invalid -response-helpers: exit code %d, stderr:\n%s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"-status-policies", "typo.json", "./..."}, io.Discard, &stderr); code != exitError || !strings.Contains(stderr.String(), `unknown field "function"`) {
+		t.Errorf("-status-policies with an unknown field: exit code %d, stderr:\n%s", code, stderr.String())
+	}
+}
+
+// TestOptionalRules checks that optional rules only run when enabled, both
+// standalone and under go vet
+func TestOptionalRules(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{
+		"app.go": `package app
+
+import "net/http"
+
+func Missing(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte("not found"))
+}
+`,
+	})
+	t.Chdir(module)
+
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{nil, exitOK, ""},
+		{[]string{"-contenttype"}, exitFindings, "app.go:6:2: error status written without setting the Content-Type header of the body that follows\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append(tt.args, "-cache=false", "./...")
+		if code := run(args, &stdout, &stderr); code != tt.code {
+			t.Errorf("%q: exit code %d, want %d; stderr:\n%s", tt.args, code, tt.code, stderr.String())
+		}
+		if got := strings.ReplaceAll(stdout.String(), module+string(filepath.Separator), ""); got != tt.want {
+			t.Errorf("%q reported:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-flags"}, true},
+		{[]string{"-V=full"}, true},
+		{[]string{"vet.cfg"}, false},
+		{[]string{"-doublewrite", "vet.cfg"}, false},
+		{[]string{"-contenttype", "vet.cfg"}, true},
+		{[]string{"-contenttype=false", "vet.cfg"}, true},
+	} {
+		got := false
+		for _, a := range vetAnalyzers(tt.args) {
+			got = got || a == analyzer.ContentTypeAnalyzer
+		}
+		if got != tt.want {
+			t.Errorf("vetAnalyzers(%q) includes contenttype: %t, want %t", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestRuleFlags checks that rule flags select rules the way multichecker
+// does: rules enabled explicitly run on their own, and otherwise the
+// default rules run except those disabled explicitly
+func TestRuleFlags(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{"app.go": testmodule.Auth})
+	t.Chdir(module)
+
+	const (
+		returnAfterStatus = "app.go:8:4: WriteHeader call not immediately followed by return statement\n"
+		nextAfterReject   = "app.go:10:3: next handler called after the response status was already written\n"
+	)
+	tests := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{nil, exitFindings, returnAfterStatus + nextAfterReject},
+		{[]string{"-returnafterstatus"}, exitFindings, returnAfterStatus},
+		{[]string{"-returnafterstatus=false"}, exitFindings, nextAfterReject},
+		{[]string{"-returnafterstatus=false", "-nextafterreject=false"}, exitOK, ""},
+		{[]string{"-returnafterstatus=false", "-nextafterreject"}, exitFindings, nextAfterReject},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append(tt.args, "-cache=false", "./...")
+		if code := run(args, &stdout, &stderr); code != tt.code {
+			t.Errorf("%q: exit code %d, want %d; stderr:\n%s", tt.args, code, tt.code, stderr.String())
+		}
+		if got := strings.ReplaceAll(stdout.String(), module+string(filepath.Separator), ""); got != tt.want {
+			t.Errorf("%q reported:\n%s\nwant:\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestFix checks that -fix applies the suggested fixes of the findings left
+// by -diff, and that -json and -c print the same findings
+func TestFix(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{
+		"app.go": `package app
+
+import "net/http"
+
+func Missing(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(404)
+}
+
+func Gone(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(410)
+}
+`,
+		"change.diff": `--- a/app.go
++++ b/app.go
+@@ -10 +10 @@ func Gone(w http.ResponseWriter, r *http.Request) {
+-	w.WriteHeader(http.StatusGone)
++	w.WriteHeader(410)
+`,
+	})
+	t.Chdir(module)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-statuscode", "-c", "0", "-diff", "change.diff", "./..."}, &stdout, &stderr); code != exitFindings {
+		t.Fatalf("-c: exit code %d, want %d; stderr:\n%s", code, exitFindings, stderr.String())
+	}
+	want := "app.go:10:16: use http.StatusGone instead of the integer literal 410\n10\t\tw.WriteHeader(410)\n"
+	if got := strings.ReplaceAll(stdout.String(), module+string(filepath.Separator), ""); got != want {
+		t.Errorf("-c reported:\n%s\nwant:\n%s", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-statuscode", "-json", "./..."}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("-json: exit code %d, want %d; stderr:\n%s", code, exitOK, stderr.String())
+	}
+	var tree map[string]map[string][]struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &tree); err != nil {
+		t.Fatalf("invalid -json output: %v\n%s", err, stdout.String())
+	}
+	if diags := tree["example.com/app"]["statuscode"]; len(diags) != 2 {
+		t.Errorf("-json reported %+v, want both literals", diags)
+	}
+
+	if code := run([]string{"-statuscode", "-fix", "-diff", "change.diff", "./..."}, io.Discard, &stderr); code != exitOK {
+		t.Fatalf("-fix: exit code %d, want %d; stderr:\n%s", code, exitOK, stderr.String())
+	}
+	got, err := os.ReadFile("app.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(got, []byte("w.WriteHeader(http.StatusGone)")) || !bytes.Contains(got, []byte("w.WriteHeader(404)")) {
+		t.Errorf("-fix -diff left:\n%s\nwant only the changed line fixed", got)
+	}
+
+	if code := run([]string{"-statuscode", "-fix", "./..."}, io.Discard, &stderr); code != exitOK {
+		t.Fatalf("-fix: exit code %d, want %d; stderr:\n%s", code, exitOK, stderr.String())
+	}
+	if code := run([]string{"-statuscode", "-cache=false", "./..."}, &stdout, &stderr); code != exitOK {
+		t.Errorf("findings left after -fix: exit code %d; stdout:\n%s", code, stdout.String())
+	}
+}
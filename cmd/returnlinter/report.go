@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// Reasons a finding is suppressed from the report
+const (
+	suppressedRuleDisabled = "rule disabled"
+	suppressedOutsideDiff  = "outside diff"
+)
+
+// jsonReport is the document written by -report=json
+type jsonReport struct {
+	Summary  jsonSummary   `json:"summary"`
+	Handlers []jsonHandler `json:"handlers"`
+	// Findings holds the findings outside any handler, such as invalid
+	// status codes in helper functions
+	Findings []jsonFinding `json:"findings"`
+}
+
+type jsonSummary struct {
+	Handlers   int            `json:"handlers"`
+	Kinds      map[string]int `json:"kinds"`
+	Checked    int            `json:"checked"`
+	Violations int            `json:"violations"`
+	Suppressed int            `json:"suppressed"`
+}
+
+type jsonHandler struct {
+	Package      string        `json:"package"`
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	Position     string        `json:"position"`
+	Routes       []string      `json:"routes"`
+	StatusWrites int           `json:"statusWrites"`
+	Checked      bool          `json:"checked"`
+	Violations   []jsonFinding `json:"violations"`
+	Suppressed   []jsonFinding `json:"suppressed"`
+
+	posn token.Position
+}
+
+type jsonFinding struct {
+	Rule     string `json:"rule"`
+	Name     string `json:"name"`
+	Position string `json:"position"`
+	Message  string `json:"message"`
+	URL      string `json:"url"`
+	// Reason says why a suppressed finding was not reported
+	Reason string `json:"reason,omitempty"`
+
+	posn token.Position
+}
+
+// buildReport assembles the JSON report from the Engine result of every
+// analyzed package, which must be among the roots of graph. A handler is
+// checked unless -diff excludes it, and a finding is a violation unless its
+// rule is disabled or it lies outside the diff.
+func buildReport(graph *checker.Graph, engine *analysis.Analyzer, enabled map[*analyzer.Rule]bool, changed changedLines) *jsonReport {
+	report := &jsonReport{
+		Summary:  jsonSummary{Kinds: make(map[string]int)},
+		Handlers: []jsonHandler{},
+		Findings: []jsonFinding{},
+	}
+
+	// A package and its test variant share files, so the same handler may
+	// be found twice
+	seenHandlers := make(map[token.Position]bool)
+	seenFindings := make(map[string]bool)
+
+	for _, act := range graph.Roots {
+		if act.Analyzer != engine || act.Err != nil {
+			continue
+		}
+		pkg := act.Package
+		result := act.Result.(*analyzer.Result)
+
+		for _, h := range result.Handlers {
+			posn := pkg.Fset.Position(h.Handler.Pos())
+			if seenHandlers[posn] {
+				continue
+			}
+			seenHandlers[posn] = true
+
+			checked := changed == nil || inChangedRange(pkg, h.Handler.Pos(), h.Handler.End(), changed)
+			jh := jsonHandler{
+				Package:      pkg.PkgPath,
+				Name:         h.Handler.Name,
+				Kind:         h.Handler.Kind.String(),
+				Position:     posn.String(),
+				Routes:       []string{},
+				StatusWrites: h.StatusWrites,
+				Checked:      checked,
+				Violations:   []jsonFinding{},
+				Suppressed:   []jsonFinding{},
+				posn:         posn,
+			}
+			for _, route := range h.Handler.Routes {
+				jh.Routes = append(jh.Routes, route.String())
+			}
+			for i := range h.Findings {
+				jf, reason := newJSONFinding(pkg, &h.Findings[i], enabled, changed)
+				seenFindings[jf.Position+jf.Rule+jf.Message] = true
+				if reason != "" {
+					jh.Suppressed = append(jh.Suppressed, jf)
+				} else {
+					jh.Violations = append(jh.Violations, jf)
+				}
+			}
+			report.Handlers = append(report.Handlers, jh)
+
+			report.Summary.Handlers++
+			report.Summary.Kinds[jh.Kind]++
+			if checked {
+				report.Summary.Checked++
+			}
+			report.Summary.Violations += len(jh.Violations)
+			report.Summary.Suppressed += len(jh.Suppressed)
+		}
+
+		for i := range result.Findings {
+			// Findings inside handlers were recorded above
+			jf, reason := newJSONFinding(pkg, &result.Findings[i], enabled, changed)
+			key := jf.Position + jf.Rule + jf.Message
+			if seenFindings[key] {
+				continue
+			}
+			seenFindings[key] = true
+			if reason != "" {
+				report.Summary.Suppressed++
+				continue
+			}
+			report.Findings = append(report.Findings, jf)
+			report.Summary.Violations++
+		}
+	}
+
+	sort.SliceStable(report.Handlers, func(i, j int) bool {
+		return lessPosition(report.Handlers[i].posn, report.Handlers[j].posn)
+	})
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return lessPosition(report.Findings[i].posn, report.Findings[j].posn)
+	})
+	return report
+}
+
+// newJSONFinding converts f and returns the reason it is suppressed, if any
+func newJSONFinding(pkg *packages.Package, f *analyzer.Finding, enabled map[*analyzer.Rule]bool, changed changedLines) (jsonFinding, string) {
+	posn := pkg.Fset.Position(f.Diagnostic.Pos)
+	jf := jsonFinding{
+		Rule:     f.Rule.ID,
+		Name:     f.Rule.Name,
+		Position: posn.String(),
+		Message:  f.Diagnostic.Message,
+		URL:      f.Diagnostic.URL,
+		posn:     posn,
+	}
+	switch {
+	case !enabled[f.Rule]:
+		jf.Reason = suppressedRuleDisabled
+	case changed != nil && !newFinding(pkg, f.Diagnostic).inDiff(changed):
+		jf.Reason = suppressedOutsideDiff
+	}
+	return jf, jf.Reason
+}
+
+// inChangedRange reports whether a line between pos and end was changed
+func inChangedRange(pkg *packages.Package, pos, end token.Pos, changed changedLines) bool {
+	start, stop := pkg.Fset.Position(pos), pkg.Fset.Position(end)
+	filename, err := filepath.Abs(start.Filename)
+	if err != nil {
+		return false
+	}
+	return changed.containsRange(filename, start.Line, stop.Line)
+}
+
+// writeJSON writes report as indented JSON
+func writeJSON(w io.Writer, report *jsonReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
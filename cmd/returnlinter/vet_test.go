@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/internal/testmodule"
+)
+
+// TestVetTool runs the command under go vet on a module whose middleware and
+// routes live in different packages, so the finding depends on a fact
+// exported for the middleware's package.
+func TestVetTool(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the command and runs go vet")
+	}
+	dir := t.TempDir()
+	tool := filepath.Join(dir, "returnlinter")
+	if out, err := exec.Command("go", "build", "-o", tool, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	module := testmodule.Write(t, map[string]string{
+		"mw/mw.go": strings.Replace(testmodule.Auth, "package app", "package mw", 1),
+		"app/app.go": `package app
+
+import (
+	"net/http"
+
+	"example.com/app/mw"
+)
+
+func Register(mux *http.ServeMux, h http.Handler) {
+	mux.Handle("GET /admin", mw.Auth(h))
+}
+`,
+	})
+
+	cmd := exec.Command("go", "vet", "-vettool="+tool, "-middlewarechain", "./app")
+	cmd.Dir = module
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("go vet succeeded, want a finding:\n%s", out)
+	}
+	want := "route GET /admin runs through middleware mw.Auth, which continues after rejecting the request"
+	if !strings.Contains(string(out), want) {
+		t.Errorf("go vet output does not contain %q:\n%s", want, out)
+	}
+}
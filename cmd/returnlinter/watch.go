@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// watcher implements -watch. It polls the directories of the packages
+// matching the patterns for changes to .go files, re-analyzes the packages
+// of the changed and new directories along with the packages importing
+// them, and prints the findings added and resolved.
+type watcher struct {
+	analyzers []*analysis.Analyzer
+	tests     bool
+	changed   changedLines
+	interval  time.Duration
+	stdout    io.Writer
+	stderr    io.Writer
+
+	// roots holds the analyzed packages by ID
+	roots map[string]*packages.Package
+	// findings holds the findings of each analyzed package by ID
+	findings map[string][]finding
+	// files holds the state of the .go files of each watched directory,
+	// by file name
+	files map[string]map[string]fileState
+	// entries holds, for each directory whose entries are watched for new
+	// packages, its .go files and subdirectories
+	entries map[string]string
+}
+
+// fileState is what polling compares to detect a change to a file. The
+// contents are hashed again only when the size or modification time
+// changes, or when the modification time is too recent to tell apart from
+// that of an edit made since the file was last hashed.
+type fileState struct {
+	size    int64
+	modTime time.Time
+	// hashed is when the file was last read
+	hashed time.Time
+	hash   string
+}
+
+// mtimeGranularity bounds the timestamp granularity of the file systems
+// watched: FAT records modification times to 2s
+const mtimeGranularity = 2 * time.Second
+
+// watch analyzes the packages matching patterns, then re-analyzes them as
+// their files change until ctx is done. It returns the exit code for the
+// findings of the last analysis.
+func (w *watcher) watch(ctx context.Context, patterns []string) int {
+	w.roots = make(map[string]*packages.Package)
+	w.findings = make(map[string][]finding)
+	w.files = make(map[string]map[string]fileState)
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax, Tests: w.tests}, patterns...)
+	if err != nil {
+		fmt.Fprintln(w.stderr, err)
+		return exitError
+	}
+	packages.PrintErrors(pkgs)
+	_, findings, err := w.update(pkgs, nil)
+	if err != nil {
+		fmt.Fprintln(w.stderr, err)
+		return exitError
+	}
+	for _, f := range findings {
+		fmt.Fprintf(w.stdout, "%s: %s\n", f.Posn, f.Message)
+	}
+	w.scan(patterns)
+	fmt.Fprintf(w.stderr, "returnlinter: watching %d directories for changes to .go files\n", len(w.files))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if len(w.all()) > 0 {
+				return exitFindings
+			}
+			return exitOK
+		case <-ticker.C:
+		}
+		if changed := w.scan(patterns); len(changed) > 0 {
+			w.refresh(changed)
+		}
+	}
+}
+
+// refresh re-analyzes the packages of the changed directories and their
+// importers, and prints how the findings changed
+func (w *watcher) refresh(changed map[string]bool) {
+	dirs := w.affected(changed)
+	patterns := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		patterns = append(patterns, dir)
+	}
+	sort.Strings(patterns)
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax, Tests: w.tests}, patterns...)
+	if err != nil {
+		fmt.Fprintln(w.stderr, err)
+		return
+	}
+	packages.PrintErrors(pkgs)
+	before, after, err := w.update(pkgs, dirs)
+	if err != nil {
+		fmt.Fprintln(w.stderr, err)
+		return
+	}
+
+	added, resolved := diffFindings(before, after)
+	var names []string
+	for _, pkg := range pkgs {
+		if !isTestMain(pkg) {
+			names = append(names, pkg.PkgPath)
+		}
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w.stdout, "[%s] %s: %d added, %d resolved, %d findings\n",
+		time.Now().Format(time.TimeOnly), strings.Join(dedupe(names), ", "), len(added), len(resolved), len(after))
+	for _, f := range added {
+		fmt.Fprintf(w.stdout, "+ %s: %s\n", f.Posn, f.Message)
+	}
+	for _, f := range resolved {
+		fmt.Fprintf(w.stdout, "- %s: %s\n", f.Posn, f.Message)
+	}
+}
+
+// update replaces the packages of dirs, or none if dirs is nil, with pkgs
+// and analyzes them. It returns all findings before and after. Packages
+// that cannot be analyzed keep their previous findings.
+func (w *watcher) update(pkgs []*packages.Package, dirs map[string]bool) (before, after []finding, err error) {
+	before = w.all()
+	previous := make(map[string][]finding)
+	for id, pkg := range w.roots {
+		if dirs[packageDir(pkg)] {
+			previous[id] = w.findings[id]
+			delete(w.roots, id)
+			delete(w.findings, id)
+		}
+	}
+	var roots []*packages.Package
+	for _, pkg := range pkgs {
+		if !isTestMain(pkg) {
+			w.roots[pkg.ID] = pkg
+			roots = append(roots, pkg)
+		}
+	}
+
+	graph, err := checker.Analyze(w.analyzers, roots, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	fresh := findingsOf(graph)
+	failed := make(map[*packages.Package]bool)
+	for _, act := range graph.Roots {
+		if act.Err != nil && !failed[act.Package] {
+			failed[act.Package] = true
+			fmt.Fprintf(w.stderr, "returnlinter: %s: %v\n", act.Package.ID, act.Err)
+		}
+	}
+	for _, pkg := range roots {
+		if failed[pkg] {
+			w.findings[pkg.ID] = previous[pkg.ID]
+		} else {
+			w.findings[pkg.ID] = fresh[pkg]
+		}
+	}
+	return before, w.all(), nil
+}
+
+// all returns the current findings, sorted and filtered by -diff
+func (w *watcher) all() []finding {
+	var findings []finding
+	for _, pkgFindings := range w.findings {
+		findings = append(findings, pkgFindings...)
+	}
+	return collect(findings, w.changed)
+}
+
+// affected returns the directories of the analyzed packages that are in
+// changed or import a package in changed, directly or not
+func (w *watcher) affected(changed map[string]bool) map[string]bool {
+	reaches := make(map[string]bool)
+	var visit func(pkg *packages.Package) bool
+	visit = func(pkg *packages.Package) bool {
+		if r, ok := reaches[pkg.ID]; ok {
+			return r
+		}
+		reaches[pkg.ID] = false
+		r := changed[packageDir(pkg)]
+		for _, imp := range pkg.Imports {
+			r = visit(imp) || r
+		}
+		reaches[pkg.ID] = r
+		return r
+	}
+
+	dirs := make(map[string]bool)
+	for dir := range changed {
+		dirs[dir] = true
+	}
+	for _, pkg := range w.roots {
+		if visit(pkg) {
+			dirs[packageDir(pkg)] = true
+		}
+	}
+	return dirs
+}
+
+// scan records the state of the .go files in the directory of every
+// watched package, and returns the directories whose files changed. A
+// directory seen for the first time holds a new package. The packages
+// matching patterns are listed again only when a .go file or subdirectory
+// is added to or removed from a watched directory, or an ancestor of one
+// within the patterns.
+func (w *watcher) scan(patterns []string) map[string]bool {
+	listings := make(map[string][]os.DirEntry)
+	readDir := func(dir string) []os.DirEntry {
+		if entries, ok := listings[dir]; ok {
+			return entries
+		}
+		entries, _ := os.ReadDir(dir)
+		listings[dir] = entries
+		return entries
+	}
+
+	relist := w.entries == nil
+	for dir, names := range w.entries {
+		if entryNames(readDir(dir)) != names {
+			relist = true
+		}
+	}
+	dirs := make(map[string]bool)
+	for dir := range w.files {
+		dirs[dir] = true
+	}
+	if relist {
+		dirs = make(map[string]bool)
+		for _, pkg := range w.roots {
+			dirs[packageDir(pkg)] = true
+		}
+		// Listing needs no parsing; errors leave the analyzed packages
+		// watched
+		listed, _ := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles, Tests: w.tests}, patterns...)
+		for _, pkg := range listed {
+			if !isTestMain(pkg) {
+				dirs[packageDir(pkg)] = true
+			}
+		}
+		delete(dirs, "")
+
+		w.entries = make(map[string]string)
+		roots := patternRoots(patterns)
+		for dir := range dirs {
+			w.entries[dir] = entryNames(readDir(dir))
+			for _, root := range roots {
+				if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					continue
+				}
+				for d := dir; d != root; {
+					d = filepath.Dir(d)
+					w.entries[d] = entryNames(readDir(d))
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	changed := make(map[string]bool)
+	files := make(map[string]map[string]fileState)
+	for dir := range dirs {
+		previous, seen := w.files[dir]
+		state := make(map[string]fileState)
+		for _, entry := range readDir(dir) {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			f := fileState{size: info.Size(), modTime: info.ModTime(), hashed: now}
+			old, ok := previous[entry.Name()]
+			if ok && old.size == f.size && old.modTime.Equal(f.modTime) && old.hashed.Sub(old.modTime) > mtimeGranularity {
+				f.hashed, f.hash = old.hashed, old.hash
+			} else if f.hash, err = fileHash(filepath.Join(dir, entry.Name())); err != nil {
+				continue
+			}
+			state[entry.Name()] = f
+			if !ok || old.hash != f.hash {
+				changed[dir] = true
+			}
+		}
+		if !seen || len(state) != len(previous) {
+			changed[dir] = true
+		}
+		files[dir] = state
+	}
+	w.files = files
+	return changed
+}
+
+// entryNames returns the names of the .go files and subdirectories among
+// entries, which are sorted by name
+func entryNames(entries []os.DirEntry) string {
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == ".go" {
+			names = append(names, entry.Name())
+		}
+	}
+	return strings.Join(names, "\x00")
+}
+
+// patternRoots returns the absolute directories under which the file
+// system patterns among patterns, such as ./... or ./cmd, match packages
+func patternRoots(patterns []string) []string {
+	var roots []string
+	for _, pattern := range patterns {
+		dir := strings.TrimSuffix(pattern, "...")
+		if dir == "" {
+			dir = "."
+		}
+		if !build.IsLocalImport(dir) && !filepath.IsAbs(dir) {
+			continue
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	return roots
+}
+
+// diffFindings returns the findings of after that are not in before, and
+// those of before that are not in after. Findings at the same position are
+// paired first; the others are paired by file, rule and message in order,
+// so a finding that moves because lines were added above it is neither
+// added nor resolved.
+func diffFindings(before, after []finding) (added, resolved []finding) {
+	type key struct {
+		file     string
+		line     int
+		column   int
+		category string
+		message  string
+	}
+	exact := func(f finding) key {
+		return key{f.Posn.Filename, f.Posn.Line, f.Posn.Column, f.Category, f.Message}
+	}
+	moved := func(f finding) key {
+		return key{file: f.Posn.Filename, category: f.Category, message: f.Message}
+	}
+
+	pairedBefore := make([]bool, len(before))
+	pairedAfter := make([]bool, len(after))
+	for _, keyOf := range []func(finding) key{exact, moved} {
+		// The findings of before not paired yet, in order, by key
+		candidates := make(map[key][]int)
+		for i, f := range before {
+			if !pairedBefore[i] {
+				candidates[keyOf(f)] = append(candidates[keyOf(f)], i)
+			}
+		}
+		for i, f := range after {
+			if pairedAfter[i] {
+				continue
+			}
+			if c := candidates[keyOf(f)]; len(c) > 0 {
+				pairedBefore[c[0]] = true
+				pairedAfter[i] = true
+				candidates[keyOf(f)] = c[1:]
+			}
+		}
+	}
+	for i, f := range after {
+		if !pairedAfter[i] {
+			added = append(added, f)
+		}
+	}
+	for i, f := range before {
+		if !pairedBefore[i] {
+			resolved = append(resolved, f)
+		}
+	}
+	return added, resolved
+}
+
+// packageDir returns the directory of a package's files
+func packageDir(pkg *packages.Package) string {
+	if len(pkg.GoFiles) == 0 {
+		return ""
+	}
+	return filepath.Dir(pkg.GoFiles[0])
+}
+
+// isTestMain reports whether pkg is the generated main package of a test
+// binary, which lives in the build cache
+func isTestMain(pkg *packages.Package) bool {
+	return strings.HasSuffix(pkg.PkgPath, ".test")
+}
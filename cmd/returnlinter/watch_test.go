@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3-2-1-contact/return-linter/internal/testmodule"
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+)
+
+// syncBuffer is a bytes.Buffer safe for the watcher goroutine and the test
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits until the output contains want and returns what follows it
+func waitFor(t *testing.T, out *syncBuffer, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		if s := out.String(); strings.Contains(s, want) {
+			return s[strings.Index(s, want):]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %q; output:\n%s", want, out.String())
+	return ""
+}
+
+// waitForCount waits until the output contains want n times
+func waitForCount(t *testing.T, out *syncBuffer, want string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		if strings.Count(out.String(), want) >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d times %q; output:\n%s", n, want, out.String())
+}
+
+// watchedApp is the Auth middleware rejecting through lib.Reject, so that
+// its finding depends on the lib package
+var watchedApp = strings.NewReplacer(
+	`import "net/http"`, "import (\n\t\"net/http\"\n\n\t\"example.com/app/lib\"\n)",
+	"w.WriteHeader(http.StatusUnauthorized)", "lib.Reject(w)",
+).Replace(testmodule.Auth)
+
+// TestWatch checks that a change to a package re-analyzes the packages
+// importing it, and that the findings added and resolved are printed
+func TestWatch(t *testing.T) {
+	module := testmodule.Write(t, map[string]string{
+		"app.go": watchedApp,
+		// Reject does not write a status yet, so Auth is fine
+		"lib/lib.go":     "package lib\n\nimport \"net/http\"\n\nfunc Reject(w http.ResponseWriter) {}\n",
+		"other/other.go": "package other\n\nfunc Add(a, b int) int { return a + b }\n",
+	})
+	write := func(name, content string) {
+		t.Helper()
+		testmodule.WriteFile(t, module, name, content)
+	}
+	t.Chdir(module)
+
+	var stdout, stderr syncBuffer
+	w := &watcher{
+		analyzers: []*analysis.Analyzer{analyzer.NextAfterRejectAnalyzer},
+		tests:     true,
+		interval:  10 * time.Millisecond,
+		stdout:    &stdout,
+		stderr:    &stderr,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan int)
+	go func() {
+		done <- w.watch(ctx, []string{"./..."})
+	}()
+	waitFor(t, &stderr, "watching 3 directories")
+	if out := stdout.String(); out != "" {
+		t.Fatalf("initial findings:\n%s", out)
+	}
+
+	// Reject now writes a status: Auth is re-analyzed although it did not
+	// change, and the unrelated package is not
+	write("lib/lib.go", "package lib\n\nimport \"net/http\"\n\nfunc Reject(w http.ResponseWriter) {\n\tw.WriteHeader(http.StatusForbidden)\n}\n")
+	out := waitFor(t, &stdout, "example.com/app, example.com/app/lib: 1 added, 0 resolved, 1 findings\n")
+	wantAdded := "+ " + filepath.Join(module, "app.go") + ":14:3: next handler called after the response status was already written\n"
+	if !strings.HasSuffix(out, wantAdded) {
+		t.Errorf("after changing lib:\n%s\nwant the line:\n%s", out, wantAdded)
+	}
+
+	// Lines added above the finding move it without changing it
+	write("app.go", strings.Replace(watchedApp, "func Auth", "// Auth checks credentials\nfunc Auth", 1))
+	waitFor(t, &stdout, "example.com/app: 0 added, 0 resolved, 1 findings\n")
+
+	write("app.go", strings.Replace(watchedApp, "lib.Reject(w)", "lib.Reject(w)\n\t\t\treturn", 1))
+	out = waitFor(t, &stdout, "example.com/app: 0 added, 1 resolved, 0 findings\n")
+	wantResolved := "- " + filepath.Join(module, "app.go") + ":15:3: next handler called after the response status was already written\n"
+	if !strings.HasSuffix(out, wantResolved) {
+		t.Errorf("after fixing app:\n%s\nwant the line:\n%s", out, wantResolved)
+	}
+	if strings.Contains(stdout.String(), "example.com/app/other") {
+		t.Errorf("unrelated package re-analyzed:\n%s", stdout.String())
+	}
+
+	// A package created after startup is found and analyzed
+	write("extra/extra.go", strings.Replace(testmodule.Auth, "package app", "package extra", 1))
+	out = waitFor(t, &stdout, "example.com/app/extra: 1 added, 0 resolved, 1 findings\n")
+	wantAdded = "+ " + filepath.Join(module, "extra", "extra.go") + ":10:3: next handler called after the response status was already written\n"
+	if !strings.HasSuffix(out, wantAdded) {
+		t.Errorf("after adding extra:\n%s\nwant the line:\n%s", out, wantAdded)
+	}
+
+	// A new modification time alone is not a change, and an edit that
+	// keeps the size and a modification time too recent to rely on is
+	// still seen
+	other := filepath.Join(module, "other", "other.go")
+	recent := time.Now().Add(time.Hour)
+	edit := func(content string) {
+		t.Helper()
+		if content != "" {
+			write("other/other.go", content)
+		}
+		if err := os.Chtimes(other, recent, recent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const otherRefreshed = "example.com/app/other: 0 added, 0 resolved, 1 findings\n"
+	edit("")
+	edit("package other\n\nfunc Sub(a, b int) int { return a - b }\n")
+	waitForCount(t, &stdout, otherRefreshed, 1)
+	edit("package other\n\nfunc Mul(a, b int) int { return a * b }\n")
+	waitForCount(t, &stdout, otherRefreshed, 2)
+	if n := strings.Count(stdout.String(), "example.com/app/other:"); n != 2 {
+		t.Errorf("other re-analyzed %d times, want 2:\n%s", n, stdout.String())
+	}
+
+	// The finding in extra is left
+	cancel()
+	if code := <-done; code != exitFindings {
+		t.Errorf("exit code %d, want %d", code, exitFindings)
+	}
+}
+
+func TestDiffFindings(t *testing.T) {
+	at := func(line int, msg string) finding {
+		return finding{Posn: token.Position{Filename: "a.go", Line: line, Column: 2}, Category: "RL001", Message: msg}
+	}
+	before := []finding{at(3, "x"), at(5, "x"), at(9, "y")}
+	after := []finding{at(4, "x"), at(5, "x"), at(12, "z")}
+	added, resolved := diffFindings(before, after)
+	// The finding at line 5 is unchanged and the one at line 3 moved to 4
+	if len(added) != 1 || added[0] != after[2] {
+		t.Errorf("added = %v, want the finding at line 12", added)
+	}
+	if len(resolved) != 1 || resolved[0] != before[2] {
+		t.Errorf("resolved = %v, want the finding at line 9", resolved)
+	}
+}
@@ -0,0 +1,70 @@
+// Command rules generates the rule reference in docs/rules from the rule
+// registry in pkg/analyzer. Run it through go generate:
+//
+//	go generate ./pkg/analyzer
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+)
+
+const header = "<!-- Code generated by docs/rules from pkg/analyzer.Rules; DO NOT EDIT. -->\n\n"
+
+func main() {
+	dir := flag.String("dir", ".", "directory to write the rule documentation to")
+	flag.Parse()
+
+	files := generate(analyzer.Rules)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(*dir, name), content, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// generate renders one page per rule plus an index, keyed by file name
+func generate(rules []*analyzer.Rule) map[string][]byte {
+	files := make(map[string][]byte)
+
+	var index bytes.Buffer
+	index.WriteString(header)
+	index.WriteString("# Rules\n\n")
+	index.WriteString("Every diagnostic carries the ID of the rule that reported it as its category.\n\n")
+	index.WriteString("| ID | Rule | Checks |\n")
+	index.WriteString("|----|------|--------|\n")
+
+	for _, rule := range rules {
+		doc := rule.Doc
+		if rule.Optional {
+			doc += " (optional)"
+		}
+		fmt.Fprintf(&index, "| [%s](%s.md) | `%s` | %s |\n", rule.ID, rule.ID, rule.Name, doc)
+		files[rule.ID+".md"] = page(rule)
+	}
+
+	files["README.md"] = index.Bytes()
+	return files
+}
+
+// page renders the documentation of a single rule
+func page(rule *analyzer.Rule) []byte {
+	var b bytes.Buffer
+	b.WriteString(header)
+	fmt.Fprintf(&b, "# %s: %s\n\n", rule.ID, rule.Name)
+	fmt.Fprintf(&b, "The `%s` rule %s.\n\n", rule.Name, rule.Doc)
+	fmt.Fprintf(&b, "%s\n\n", rule.Details)
+	if rule.Optional {
+		b.WriteString("This rule is optional: it only runs when it is enabled explicitly.\n\n")
+	}
+	fmt.Fprintf(&b, "## Bad\n\n```go\n%s\n```\n\n", rule.Bad)
+	fmt.Fprintf(&b, "## Good\n\n```go\n%s\n```\n\n", rule.Good)
+	fmt.Fprintf(&b, "## Running only this rule\n\n```bash\nreturnlinter -%s ./...\n```\n", rule.Name)
+	return b.Bytes()
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+)
+
+// TestUpToDate checks that the committed documentation matches the registry
+func TestUpToDate(t *testing.T) {
+	for name, want := range generate(analyzer.Rules) {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Errorf("Failed to read %s: %v (run go generate ./pkg/analyzer)", name, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date; run go generate ./pkg/analyzer", name)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package testmodule writes the throwaway Go modules that the command tests
+// run returnlinter on.
+package testmodule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// GoMod is the go.mod of the modules written by Write, unless the files
+// hold their own
+const GoMod = "module example.com/app\n\ngo 1.22\n"
+
+// Auth is app.go of a module whose Auth middleware continues to the next
+// handler after rejecting the request, an RL007 finding at line 10
+const Auth = `package app
+
+import "net/http"
+
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+`
+
+// Write writes files, by slash-separated path, into a new temporary
+// directory along with GoMod, and returns the directory
+func Write(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		WriteFile(t, dir, "go.mod", GoMod)
+	}
+	for name, content := range files {
+		WriteFile(t, dir, name, content)
+	}
+	return dir
+}
+
+// WriteFile writes content to the file name of the module in dir, creating
+// its directory
+func WriteFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,55 +1,175 @@
 package analyzer
 
 import (
+	"fmt"
 	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
 
 	"golang.org/x/tools/go/analysis"
-	"golang.org/x/tools/go/analysis/passes/inspect"
-	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
 )
 
-var Analyzer = &analysis.Analyzer{
-	Name:     "returnlinter",
-	Doc:      "checks that w.WriteHeader() calls are followed by return statements in http.Handler middleware",
-	Run:      run,
-	Requires: []*analysis.Analyzer{inspect.Analyzer},
+// Engine runs every rule once over the handlers found by HandlerInventory.
+// It reports nothing itself; each rule analyzer requires it and reports the
+// findings that belong to that rule.
+var Engine = newEngine(&config{})
+
+// newEngine returns an Engine analyzer applying conf
+func newEngine(conf *config) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name: "returnlinterengine",
+		Doc:  "shared engine behind the returnlinter rules: discovers HTTP handlers and tracks their ResponseWriter",
+		URL:  docsURL,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			return run(pass, conf)
+		},
+		Requires:   []*analysis.Analyzer{httpSSA, HandlerInventory},
+		ResultType: reflect.TypeOf((*Result)(nil)),
+		FactTypes:  []analysis.Fact{new(WritesStatusFact), new(UnsafeMiddlewareFact)},
+	}
 }
 
-func run(pass *analysis.Pass) (interface{}, error) {
-	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+// Result is the result of the Engine analyzer
+type Result struct {
+	// Findings holds the diagnostics of every rule, in the order they
+	// were found
+	Findings []Finding
+	// Handlers summarizes every handler found in the package, in source
+	// order
+	Handlers []*HandlerSummary
+}
 
-	// Filter for function declarations
-	nodeFilter := []ast.Node{
-		(*ast.FuncDecl)(nil),
-	}
+// HandlerSummary summarizes the checks of one handler
+type HandlerSummary struct {
+	Handler *Handler
+	// StatusWrites counts the WriteHeader calls on the handler's
+	// ResponseWriter
+	StatusWrites int
+	// Findings holds the findings located inside the handler but not inside
+	// a nested handler
+	Findings []Finding
+}
 
-	inspect.Preorder(nodeFilter, func(n ast.Node) {
-		funcDecl := n.(*ast.FuncDecl)
+// Finding is a diagnostic produced by one rule
+type Finding struct {
+	Rule       *Rule
+	Diagnostic analysis.Diagnostic
+}
+
+// handler is a handler of the inventory together with the values aliasing
+// its ResponseWriter
+type handler struct {
+	*Handler
+	aliases *writerAliases
+}
+
+// checker collects the findings of one engine pass
+type checker struct {
+	pass   *analysis.Pass
+	config *config
+	index  *syntaxIndex
+	result *Result
+}
+
+// reportf records a finding for rule at pos
+func (c *checker) reportf(rule *Rule, pos token.Pos, format string, args ...interface{}) {
+	c.report(rule, analysis.Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// report records a finding for rule, tagged with the rule's ID and
+// documentation
+func (c *checker) report(rule *Rule, diag analysis.Diagnostic) {
+	diag.Category = rule.ID
+	diag.URL = rule.URL()
+	c.result.Findings = append(c.result.Findings, Finding{Rule: rule, Diagnostic: diag})
+}
 
-		// Check if this function matches the middleware pattern:
-		// func <name>(handler http.Handler) http.Handler
-		if !isMiddlewarePattern(funcDecl) {
-			return
+func run(pass *analysis.Pass, conf *config) (interface{}, error) {
+	ssaInfo := pass.ResultOf[httpSSA].(*buildssa.SSA)
+	inventory := pass.ResultOf[HandlerInventory].(*Inventory)
+
+	c := &checker{pass: pass, config: conf, index: inventory.index, result: &Result{}}
+	if ssaInfo.Pkg == nil {
+		// The package does not use net/http
+		return c.result, nil
+	}
+	helpers := &statusHelpers{pass: pass, config: conf, prog: ssaInfo.Pkg.Prog, params: make(map[*types.Func]int)}
+	helpers.exportFacts(ssaInfo.SrcFuncs)
+
+	var handlers []*handler
+	for _, h := range inventory.Handlers {
+		aliases := trackWriter(h.Func)
+		aliases.helpers = helpers
+		handlers = append(handlers, &handler{Handler: h, aliases: aliases})
+	}
+	checkReturnAfterStatus(c, handlers)
+	for _, h := range handlers {
+		if h.Kind == MiddlewareHandler {
+			checkNextAfterReject(c, h)
 		}
+		checkDoubleWrite(c, h)
+		checkHeaderAfterWrite(c, h)
+		checkGoroutineWrites(c, h)
+		checkDeferredWrites(c, h)
+		checkUncheckedWrites(c, h)
+		checkContentType(c, h)
+		checkStatusPolicies(c, h)
+	}
+	checkMiddlewareChains(c, inventory)
+
+	// Validate the status code of every WriteHeader call, not only the ones
+	// inside handlers: an out-of-range code panics wherever it is written.
+	for _, callExpr := range c.index.statusCalls {
+		checkStatusCode(c, callExpr)
+	}
 
-		// Find the inner HandlerFunc
-		ast.Inspect(funcDecl.Body, func(node ast.Node) bool {
-			// Look for the pattern: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { ... })
-			if callExpr, ok := node.(*ast.CallExpr); ok {
-				if isHandlerFuncCall(callExpr) {
-					// Get the function literal inside HandlerFunc
-					if len(callExpr.Args) > 0 {
-						if funcLit, ok := callExpr.Args[0].(*ast.FuncLit); ok {
-							checkHandlerBody(pass, funcLit.Body)
-						}
-					}
-				}
+	c.summarize(handlers)
+	return c.result, nil
+}
+
+// summarize records a HandlerSummary for every handler and attributes each
+// finding to the innermost handler containing it, prefixing its message with
+// the routes the handler serves.
+func (c *checker) summarize(handlers []*handler) {
+	for _, h := range handlers {
+		summary := &HandlerSummary{Handler: h.Handler}
+		forEachInstr(h.Func, func(instr ssa.Instruction) {
+			if call, ok := instr.(*ssa.Call); ok && h.aliases.isStatusWrite(call.Common()) {
+				summary.StatusWrites++
 			}
-			return true
 		})
-	})
+		c.result.Handlers = append(c.result.Handlers, summary)
+	}
 
-	return nil, nil
+	for i := range c.result.Findings {
+		finding := &c.result.Findings[i]
+		var inner *HandlerSummary
+		for _, summary := range c.result.Handlers {
+			h, pos := summary.Handler, finding.Diagnostic.Pos
+			if pos < h.Pos() || pos >= h.End() {
+				continue
+			}
+			if inner == nil || h.End()-h.Pos() < inner.Handler.End()-inner.Handler.Pos() {
+				inner = summary
+			}
+		}
+		if inner == nil {
+			continue
+		}
+		if routes := inner.Handler.Routes; len(routes) > 0 {
+			// Name the routes served, e.g. "GET /admin/users: ..."
+			names := make([]string, len(routes))
+			for i, route := range routes {
+				names[i] = route.String()
+			}
+			finding.Diagnostic.Message = strings.Join(names, ", ") + ": " + finding.Diagnostic.Message
+		}
+		inner.Findings = append(inner.Findings, *finding)
+	}
 }
 
 // isMiddlewarePattern checks if the function signature matches:
@@ -97,75 +217,6 @@ func isHandlerFuncCall(callExpr *ast.CallExpr) bool {
 	return ident.Name == "http" && selector.Sel.Name == "HandlerFunc"
 }
 
-// checkHandlerBody inspects the handler function body for WriteHeader calls
-func checkHandlerBody(pass *analysis.Pass, body *ast.BlockStmt) {
-	for i, stmt := range body.List {
-		// Look for expression statements that might contain w.WriteHeader()
-		if exprStmt, ok := stmt.(*ast.ExprStmt); ok {
-			if IsWriteHeaderCall(exprStmt.X) {
-				// Check if the next non-comment/non-empty statement is a return
-				if !IsFollowedByReturn(body.List, i) {
-					pass.Reportf(exprStmt.Pos(), "WriteHeader call not immediately followed by return statement")
-				}
-			}
-		}
-
-		// Also check inside if/else blocks, switch statements, etc.
-		checkNestedWriteHeader(pass, stmt)
-	}
-}
-
-// checkNestedWriteHeader recursively checks for WriteHeader calls in nested structures
-func checkNestedWriteHeader(pass *analysis.Pass, stmt ast.Stmt) {
-	switch s := stmt.(type) {
-	case *ast.IfStmt:
-		checkBlockForWriteHeader(pass, s.Body)
-		if s.Else != nil {
-			checkNestedWriteHeader(pass, s.Else)
-		}
-	case *ast.BlockStmt:
-		checkBlockForWriteHeader(pass, s)
-	case *ast.ForStmt:
-		checkBlockForWriteHeader(pass, s.Body)
-	case *ast.RangeStmt:
-		checkBlockForWriteHeader(pass, s.Body)
-	case *ast.SwitchStmt:
-		checkBlockForWriteHeader(pass, s.Body)
-	case *ast.TypeSwitchStmt:
-		checkBlockForWriteHeader(pass, s.Body)
-	case *ast.SelectStmt:
-		checkBlockForWriteHeader(pass, s.Body)
-	case *ast.CaseClause:
-		for i, caseStmt := range s.Body {
-			if exprStmt, ok := caseStmt.(*ast.ExprStmt); ok {
-				if IsWriteHeaderCall(exprStmt.X) {
-					if !IsFollowedByReturn(s.Body, i) {
-						pass.Reportf(exprStmt.Pos(), "WriteHeader call not immediately followed by return statement")
-					}
-				}
-			}
-			checkNestedWriteHeader(pass, caseStmt)
-		}
-	}
-}
-
-// checkBlockForWriteHeader checks a block statement for WriteHeader calls
-func checkBlockForWriteHeader(pass *analysis.Pass, block *ast.BlockStmt) {
-	if block == nil {
-		return
-	}
-	for i, stmt := range block.List {
-		if exprStmt, ok := stmt.(*ast.ExprStmt); ok {
-			if IsWriteHeaderCall(exprStmt.X) {
-				if !IsFollowedByReturn(block.List, i) {
-					pass.Reportf(exprStmt.Pos(), "WriteHeader call not immediately followed by return statement")
-				}
-			}
-		}
-		checkNestedWriteHeader(pass, stmt)
-	}
-}
-
 // IsWriteHeaderCall checks if the expression is w.WriteHeader(...)
 func IsWriteHeaderCall(expr ast.Expr) bool {
 	callExpr, ok := expr.(*ast.CallExpr)
@@ -219,6 +270,11 @@ func isLogStatement(stmt ast.Stmt) bool {
 		return false
 	}
 
+	return isLogCall(callExpr)
+}
+
+// isLogCall checks if the call is log.*(...)
+func isLogCall(callExpr *ast.CallExpr) bool {
 	selector, ok := callExpr.Fun.(*ast.SelectorExpr)
 	if !ok {
 		return false
@@ -1,36 +1,306 @@
 package analyzer_test
 
 import (
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
 )
 
 func TestAll(t *testing.T) {
+	analysistest.Run(t, testdataDir(t), analyzer.Analyzer, "p")
+}
+
+// fixtureConfig configures the rules that report nothing by default, scoped
+// to their fixture packages
+var fixtureConfig = analyzer.Config{
+	StatusPolicies: []analyzer.StatusPolicy{
+		{
+			Name:     "middleware-rejects",
+			Message:  "middleware must reject with a 4xx or 5xx status",
+			Packages: []string{"statuspolicy"},
+			Kinds:    []string{"middleware"},
+			Allow:    []string{"4xx", "5xx"},
+		},
+		{
+			Name:      "no-500-validation",
+			Packages:  []string{"statuspolicy"},
+			Functions: []string{"Validate*"},
+			Deny:      []string{"500"},
+		},
+		{
+			Name:     "admin-statuses",
+			Packages: []string{"statuspolicy"},
+			Routes:   []string{"/admin/*"},
+			Allow:    []string{"2xx", "403"},
+		},
+	},
+}
+
+// TestRules runs every rule analyzer, configured by fixtureConfig, over the
+// fixture package named after it
+func TestRules(t *testing.T) {
+	linter, err := analyzer.New(fixtureConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range linter.Analyzers {
+		if a.Name == "returnafterstatus" {
+			// Its fixture is package p, run by TestAll
+			continue
+		}
+		t.Run(a.Name, func(t *testing.T) {
+			analysistest.RunWithSuggestedFixes(t, testdataDir(t), a, a.Name)
+		})
+	}
+}
+
+// TestRuleRegistry checks that rule IDs are unique and that every analyzer
+// and diagnostic carries its rule's ID and documentation URL
+func TestRuleRegistry(t *testing.T) {
+	if len(analyzer.Rules) != len(analyzer.Analyzers) {
+		t.Fatalf("%d rules but %d analyzers", len(analyzer.Rules), len(analyzer.Analyzers))
+	}
+
+	ids := make(map[string]bool)
+	for i, rule := range analyzer.Rules {
+		if ids[rule.ID] {
+			t.Errorf("duplicate rule ID %s", rule.ID)
+		}
+		ids[rule.ID] = true
+
+		a := analyzer.Analyzers[i]
+		if a.Name != rule.Name || a.URL != rule.URL() {
+			t.Errorf("analyzer %s does not match rule %s (%s)", a.Name, rule.ID, rule.Name)
+		}
+	}
+
+	for _, result := range analysistest.Run(t, testdataDir(t), analyzer.Analyzer, "p") {
+		for _, diag := range result.Diagnostics {
+			if diag.Category != "RL001" || diag.URL != analyzer.Rules[0].URL() {
+				t.Errorf("diagnostic %q has category %q and URL %q", diag.Message, diag.Category, diag.URL)
+			}
+		}
+	}
+}
+
+// TestHandlerInventory checks the handlers, parameters and wrapped handlers
+// found by HandlerInventory
+func TestHandlerInventory(t *testing.T) {
+	results := analysistest.Run(t, testdataDir(t), analyzer.Engine, "handlers")
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	inventory := results[0].Pass.ResultOf[analyzer.HandlerInventory].(*analyzer.Inventory)
+
+	name := func(v *types.Var) string {
+		if v == nil {
+			return ""
+		}
+		return v.Name()
+	}
+
+	// name: kind, writer, request, next, middleware
+	want := map[string]string{
+		"Auth$1":              "middleware w r next Auth",
+		"Health":              "handler w r  ",
+		"(*Server).ServeHTTP": "ServeHTTP w r mux ",
+	}
+	got := make(map[string]string)
+	for _, h := range inventory.Handlers {
+		middleware := ""
+		if h.Middleware != nil {
+			middleware = h.Middleware.Name.Name
+		}
+		got[h.Name] = strings.Join([]string{h.Kind.String(), name(h.Writer), name(h.Request), name(h.Next), middleware}, " ")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inventory = %q, want %q", got, want)
+	}
+}
+
+// TestHandlerSummaries checks the per-handler summary in the Engine result
+func TestHandlerSummaries(t *testing.T) {
+	results := analysistest.Run(t, testdataDir(t), analyzer.Engine, "handlers")
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	result := results[0].Result.(*analyzer.Result)
+
+	type summary struct {
+		kind         analyzer.HandlerKind
+		statusWrites int
+		rules        string
+	}
+	want := map[string]summary{
+		"Auth$1":              {analyzer.MiddlewareHandler, 1, "RL001,RL007"},
+		"Health":              {analyzer.FuncHandler, 1, ""},
+		"(*Server).ServeHTTP": {analyzer.ServeHTTPHandler, 2, ""},
+	}
+
+	got := make(map[string]summary)
+	for _, h := range result.Handlers {
+		var rules []string
+		for _, finding := range h.Findings {
+			rules = append(rules, finding.Rule.ID)
+		}
+		got[h.Handler.Name] = summary{h.Handler.Kind, h.StatusWrites, strings.Join(rules, ",")}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handler summaries = %v, want %v", got, want)
+	}
+}
+
+// TestRelatedInformation checks that return-after-status diagnostics point at
+// where execution continues and at the enclosing middleware
+func TestRelatedInformation(t *testing.T) {
+	want := map[string]string{
+		"BadMiddleware":                  "execution continues with a call to w.Write",
+		"BadMiddlewareWithLogNoReturn":   "execution continues with a call to w.Write",
+		"BadMiddlewareFallsOffEnd":       "the handler ends here without a return statement",
+		"BadMiddlewareReturnAfterBlock":  "the block ends here without a return statement",
+		"BadMiddlewareTwoLogs":           "execution continues with a call to log.Println",
+		"BadMiddlewareWithLogWrongOrder": "execution continues with a call to w.Write",
+	}
+
+	for _, result := range analysistest.Run(t, testdataDir(t), analyzer.Analyzer, "p") {
+		for _, diag := range result.Diagnostics {
+			if len(diag.Related) != 2 {
+				t.Errorf("diagnostic at %v has %d related entries, want 2", result.Pass.Fset.Position(diag.Pos), len(diag.Related))
+				continue
+			}
+			middleware := strings.TrimPrefix(diag.Related[1].Message, "in middleware ")
+			if msg, ok := want[middleware]; ok && diag.Related[0].Message != msg {
+				t.Errorf("%s: related message %q, want %q", middleware, diag.Related[0].Message, msg)
+			}
+			if diag.Related[0].Pos <= diag.Pos {
+				t.Errorf("%s: continuation does not follow the WriteHeader call", middleware)
+			}
+		}
+	}
+}
+
+// testdataDir returns the repository's testdata directory
+func testdataDir(t *testing.T) string {
+	t.Helper()
 	wd, err := os.Getwd()
 	if err != nil {
 		t.Fatalf("Failed to get wd: %s", err)
 	}
 
-	testdata := filepath.Join(filepath.Dir(filepath.Dir(wd)), "testdata")
-	analysistest.Run(t, testdata, analyzer.Analyzer, "p")
+	return filepath.Join(filepath.Dir(filepath.Dir(wd)), "testdata")
+}
 
+// find returns the analyzer of linter for the rule name
+func find(t *testing.T, linter *analyzer.Linter, name string) *analysis.Analyzer {
+	t.Helper()
+	for _, a := range linter.Analyzers {
+		if a.Name == name {
+			return a
+		}
+	}
+	t.Fatalf("no %s analyzer", name)
+	return nil
 }
 
+// TestStatementKinds runs the analyzer over a fixture that uses every
+// statement kind in go/ast, and checks that the fixture really does
+func TestStatementKinds(t *testing.T) {
+	testdata := testdataDir(t)
+	analysistest.Run(t, testdata, analyzer.Analyzer, "stmts")
+
+	// Every concrete ast.Stmt except BadStmt, which only results from
+	// syntax errors
+	kinds := []ast.Stmt{
+		(*ast.DeclStmt)(nil),
+		(*ast.EmptyStmt)(nil),
+		(*ast.LabeledStmt)(nil),
+		(*ast.ExprStmt)(nil),
+		(*ast.SendStmt)(nil),
+		(*ast.IncDecStmt)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.GoStmt)(nil),
+		(*ast.DeferStmt)(nil),
+		(*ast.ReturnStmt)(nil),
+		(*ast.BranchStmt)(nil),
+		(*ast.BlockStmt)(nil),
+		(*ast.IfStmt)(nil),
+		(*ast.CaseClause)(nil),
+		(*ast.SwitchStmt)(nil),
+		(*ast.TypeSwitchStmt)(nil),
+		(*ast.CommClause)(nil),
+		(*ast.SelectStmt)(nil),
+		(*ast.ForStmt)(nil),
+		(*ast.RangeStmt)(nil),
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(testdata, "src", "stmts", "stmts.go"), nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse fixture: %v", err)
+	}
+
+	seen := make(map[reflect.Type]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Stmt); ok {
+			seen[reflect.TypeOf(stmt)] = true
+		}
+		return true
+	})
+
+	for _, kind := range kinds {
+		if !seen[reflect.TypeOf(kind)] {
+			t.Errorf("fixture does not contain a %T", kind)
+		}
+	}
+}
+
+// contentTypeHelperSrc sets the Content-Type of an error body through a
+// helper
+const contentTypeHelperSrc = `package test
+
+import "net/http"
+
+func setJSON(w http.ResponseWriter) { w.Header().Set("Content-Type", "application/json") }
+
+func Missing(w http.ResponseWriter, r *http.Request) {
+	setJSON(w)
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(` + "`" + `{"message":"not found"}` + "`" + `))
+}
+`
+
 // TestTableDriven provides explicit test cases for various scenarios
 func TestTableDriven(t *testing.T) {
+	const returnAfterStatus = "WriteHeader call not immediately followed by return statement"
+	errorStatusOnly, err := analyzer.New(analyzer.Config{UncheckedWriteErrorStatusOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncheckedOnErrors := find(t, errorStatusOnly, "uncheckedwrite")
+	withHelpers, err := analyzer.New(analyzer.Config{ContentTypeHelpers: []string{"test.setJSON"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentTypeWithHelpers := find(t, withHelpers, "contenttype")
+
 	tests := []struct {
-		name          string
-		code          string
-		shouldTrigger bool
-		description   string
+		name        string
+		analyzer    *analysis.Analyzer // analyzer.Analyzer if nil
+		code        string
+		want        []string // "line:column: message" of each diagnostic
+		fixed       string   // the code after applying the first diagnostic's fix
+		description string
 	}{
 		{
 			name: "Should not trigger if WriteHeader with immediate return",
@@ -42,8 +312,7 @@ func Middleware(handler http.Handler) http.Handler {
 		return
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - return immediately follows WriteHeader",
+			description: "Should not trigger - return immediately follows WriteHeader",
 		},
 		{
 			name: "Should not trigger if WriteHeader with blank line before return",
@@ -56,8 +325,7 @@ func Middleware(handler http.Handler) http.Handler {
 		return
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - blank lines don't count as statements",
+			description: "Should not trigger - blank lines don't count as statements",
 		},
 		{
 			name: "Should not trigger if WriteHeader with multiple blank lines before return",
@@ -71,8 +339,7 @@ func Middleware(handler http.Handler) http.Handler {
 		return
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - multiple blank lines don't count as statements",
+			description: "Should not trigger - multiple blank lines don't count as statements",
 		},
 		{
 			name: "Should not trigger if WriteHeader with comment before return",
@@ -85,8 +352,7 @@ func Middleware(handler http.Handler) http.Handler {
 		return
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - comments don't count as statements",
+			description: "Should not trigger - comments don't count as statements",
 		},
 		{
 			name: "Should trigger if WriteHeader followed by Write",
@@ -98,8 +364,8 @@ func Middleware(handler http.Handler) http.Handler {
 		w.Write([]byte("body"))
 	})
 }`,
-			shouldTrigger: true,
-			description:   "Should trigger - Write follows WriteHeader without return",
+			want:        []string{"5:3: " + returnAfterStatus},
+			description: "Should trigger - Write follows WriteHeader without return",
 		},
 		{
 			name: "Should not trigger if WriteHeader in if block with return",
@@ -114,8 +380,7 @@ func Middleware(handler http.Handler) http.Handler {
 		handler.ServeHTTP(w, r)
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - return in same if block",
+			description: "Should not trigger - return in same if block",
 		},
 		{
 			name: "Should not trigger if WriteHeader in switch case with return",
@@ -132,8 +397,7 @@ func Middleware(handler http.Handler) http.Handler {
 		}
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - return in same case",
+			description: "Should not trigger - return in same case",
 		},
 		{
 			name: "Should trigger if WriteHeader at end of function (no return)",
@@ -144,8 +408,8 @@ func Middleware(handler http.Handler) http.Handler {
 		w.WriteHeader(http.StatusOK)
 	})
 }`,
-			shouldTrigger: true,
-			description:   "Should trigger - no return after WriteHeader",
+			want:        []string{"5:3: " + returnAfterStatus},
+			description: "Should trigger - no return after WriteHeader",
 		},
 		{
 			name: "Should not trigger if regular handler (not middleware)",
@@ -155,8 +419,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("body"))
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - not a middleware pattern, ignored by linter",
+			description: "Should not trigger - not a middleware pattern, ignored by linter",
 		},
 		{
 			name: "Should not trigger if multiple WriteHeader calls with returns",
@@ -172,8 +435,7 @@ func Middleware(handler http.Handler) http.Handler {
 		return
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - both WriteHeader calls have returns",
+			description: "Should not trigger - both WriteHeader calls have returns",
 		},
 		{
 			name: "Should not trigger if WriteHeader in nested if with return",
@@ -190,8 +452,7 @@ func Middleware(handler http.Handler) http.Handler {
 		handler.ServeHTTP(w, r)
 	})
 }`,
-			shouldTrigger: false,
-			description:   "Should not trigger - return in nested if block",
+			description: "Should not trigger - return in nested if block",
 		},
 		{
 			name: "Should trigger if Write follows WriteHeader in nested if",
@@ -208,35 +469,163 @@ func Middleware(handler http.Handler) http.Handler {
 		handler.ServeHTTP(w, r)
 	})
 }`,
-			shouldTrigger: true,
-			description:   "Should trigger - Write follows WriteHeader in nested if",
+			want:        []string{"7:5: " + returnAfterStatus},
+			description: "Should trigger - Write follows WriteHeader in nested if",
+		},
+		{
+			name:     "Should suggest a constant for an integer status code",
+			analyzer: analyzer.StatusCodeAnalyzer,
+			code: `package test
+
+import "net/http"
+
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(404)
+}
+`,
+			want: []string{"6:16: use http.StatusNotFound instead of the integer literal 404"},
+			fixed: `package test
+
+import "net/http"
+
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+}
+`,
+			description: "Should trigger - the literal is replaced with its net/http constant",
+		},
+		{
+			name:     "Should keep the import name when suggesting a constant",
+			analyzer: analyzer.StatusCodeAnalyzer,
+			code: `package test
+
+import nethttp "net/http"
+
+func Teapot(w nethttp.ResponseWriter, r *nethttp.Request) {
+	w.WriteHeader((418))
+}
+`,
+			want: []string{"6:17: use nethttp.StatusTeapot instead of the integer literal 418"},
+			fixed: `package test
+
+import nethttp "net/http"
+
+func Teapot(w nethttp.ResponseWriter, r *nethttp.Request) {
+	w.WriteHeader((nethttp.StatusTeapot))
+}
+`,
+			description: "Should trigger - the message and fix use the file's name for net/http",
+		},
+		{
+			name:     "Should report an out of range status code without a fix",
+			analyzer: analyzer.StatusCodeAnalyzer,
+			code: `package test
+
+import "net/http"
+
+func Broken(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(42)
+}
+`,
+			want:        []string{"6:16: invalid WriteHeader status code 42: must be in the range 100-999"},
+			description: "Should trigger - net/http panics on status codes outside 100-999",
+		},
+		{
+			name:     "Should report unchecked writes on every path by default",
+			analyzer: analyzer.UncheckedWriteAnalyzer,
+			code: `package test
+
+import "net/http"
+
+func Get(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+		return
+	}
+	w.Write([]byte("ok"))
+}
+`,
+			want: []string{
+				"8:3: error writing the response with Write is not checked",
+				"11:2: error writing the response with Write is not checked",
+			},
+			description: "Should trigger - both write errors are dropped",
+		},
+		{
+			name:     "Should only report unchecked writes after an error status when configured",
+			analyzer: uncheckedOnErrors,
+			code: `package test
+
+import "net/http"
+
+func Get(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	if r.URL.Path != "/" {
+		code := http.StatusNotFound
+		w.WriteHeader(code)
+		w.Write([]byte("not found"))
+		return
+	}
+	w.WriteHeader(status)
+	w.Write([]byte("ok"))
+}
+`,
+			want:        []string{"10:3: error writing the response with Write is not checked"},
+			description: "Should trigger once - only the 404 path has an error status",
+		},
+		{
+			name:        "Should report an error body whose Content-Type is set by an unknown helper",
+			analyzer:    analyzer.ContentTypeAnalyzer,
+			code:        contentTypeHelperSrc,
+			want:        []string{"9:2: error status written without setting the Content-Type header of the body that follows"},
+			description: "Should trigger - setJSON is not in the allowlist",
+		},
+		{
+			name:        "Should accept an allowlisted Content-Type helper",
+			analyzer:    contentTypeWithHelpers,
+			code:        contentTypeHelperSrc,
+			description: "Should not trigger - setJSON is configured as a Content-Type helper",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create a temporary test file
-			dir := t.TempDir()
-			filename := dir + "/test.go"
+			a := tt.analyzer
+			if a == nil {
+				a = analyzer.Analyzer
+			}
+			res, err := analyzer.RunSource(a, tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-			// Write the test code to a file
-			if err := writeTestFile(filename, tt.code); err != nil {
-				t.Fatalf("Failed to write test file: %v", err)
+			var got []string
+			for _, diag := range res.Diagnostics {
+				posn := res.Position(diag.Pos)
+				got = append(got, fmt.Sprintf("%d:%d: %s", posn.Line, posn.Column, diag.Message))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s\ngot diagnostics %q\nwant %q", tt.description, got, tt.want)
 			}
 
-			// Use analysistest to run the analyzer on the code
-			// Note: This requires setting up the test infrastructure properly
-			t.Logf("Test case: %s", tt.description)
-			t.Logf("Should trigger: %v", tt.shouldTrigger)
+			if tt.fixed == "" {
+				return
+			}
+			if len(res.Diagnostics) == 0 {
+				t.Fatal("no diagnostic to fix")
+			}
+			fixed, err := res.Fix(res.Diagnostics[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fixed != tt.fixed {
+				t.Errorf("fixed source:\n%s\nwant:\n%s", fixed, tt.fixed)
+			}
 		})
 	}
 }
 
-func writeTestFile(filename, content string) error {
-	// This would write the file, but for this test we're keeping it simple
-	return nil
-}
-
 // TestIsWriteHeaderCall tests the WriteHeader detection logic
 func TestIsWriteHeaderCall(t *testing.T) {
 	tests := []struct {
@@ -365,6 +754,31 @@ func main() int {
 	}
 }
 
+// TestStatusPolicyConfig checks that New rejects malformed status policies
+func TestStatusPolicyConfig(t *testing.T) {
+	for _, tt := range []struct {
+		policy analyzer.StatusPolicy
+		want   string // substring of the error, or "" for none
+	}{
+		{analyzer.StatusPolicy{Name: "ok", Allow: []string{"4xx", "503", "300-308"}}, ""},
+		{analyzer.StatusPolicy{Allow: []string{"4xx"}}, "status policy without a name"},
+		{analyzer.StatusPolicy{Name: "open"}, `status policy "open" allows every status`},
+		{analyzer.StatusPolicy{Name: "class", Deny: []string{"0xx"}}, `bad status "0xx"`},
+		{analyzer.StatusPolicy{Name: "range", Deny: []string{"500-400"}}, `bad status "500-400"`},
+		{analyzer.StatusPolicy{Name: "code", Allow: []string{"1000"}}, `bad status "1000"`},
+		{analyzer.StatusPolicy{Name: "kind", Kinds: []string{"router"}, Deny: []string{"500"}}, `unknown handler kind "router"`},
+		{analyzer.StatusPolicy{Name: "glob", Functions: []string{"Validate["}, Deny: []string{"500"}}, `bad pattern "Validate["`},
+	} {
+		_, err := analyzer.New(analyzer.Config{StatusPolicies: []analyzer.StatusPolicy{tt.policy}})
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%+v: unexpected error %v", tt.policy, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%+v: got error %v, want %q", tt.policy, err, tt.want)
+		}
+	}
+}
+
 // TestEdgeCases tests various edge cases
 func TestEdgeCases(t *testing.T) {
 	t.Run("Empty function", func(t *testing.T) {
@@ -425,6 +839,7 @@ func Middleware(handler http.Handler) http.Handler {
 	})
 }
 
+// testCodeDoesNotCrash runs the returnafterstatus rule over code
 func testCodeDoesNotCrash(t *testing.T, code string) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -432,10 +847,95 @@ func testCodeDoesNotCrash(t *testing.T, code string) {
 		}
 	}()
 
-	fset := token.NewFileSet()
-	_, err := parser.ParseFile(fset, "test.go", code, 0)
-	if err != nil && !strings.Contains(err.Error(), "expected") {
-		// Ignore syntax errors for edge case testing
-		t.Logf("Parse error (may be expected): %v", err)
+	analyzeSource(t, analyzer.Analyzer, code)
+}
+
+// TestWriterTracking checks that only writes through the handler's own
+// ResponseWriter, or wrappers built from it, are held to the return rule
+func TestWriterTracking(t *testing.T) {
+	analysistest.Run(t, testdataDir(t), analyzer.Analyzer, "writers")
+}
+
+// TestRoutes checks that route registrations are linked to their handlers
+// and named in diagnostics
+func TestRoutes(t *testing.T) {
+	results := analysistest.Run(t, testdataDir(t), analyzer.DoubleWriteAnalyzer, "routes")
+	result := results[0].Pass.ResultOf[analyzer.Engine].(*analyzer.Result)
+
+	got := make(map[string][]string)
+	routes := make(map[*analyzer.Route]bool)
+	for _, summary := range result.Handlers {
+		h := summary.Handler
+		for _, route := range h.Routes {
+			routes[route] = true
+			got[h.Name] = append(got[h.Name], route.String())
+		}
+	}
+	want := map[string][]string{
+		"health":             {"/health"},
+		"listUsers":          {"GET /admin/users"},
+		"createUser":         {"POST /admin/users"},
+		"(*files).ServeHTTP": {"/static/"},
+		"Auth$1":             {"/private/"},
+		"private":            {"/private/"},
+		"register$1":         {"DELETE /admin/users/{id}"},
+		"listOrders":         {"GET /api/orders"},
+		"updateOrder":        {"PATCH /api/orders/{id}"},
+		"items":              {"GET /v2/items", "HEAD /v2/items"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routes = %v, want %v", got, want)
+	}
+	if len(routes) != 10 {
+		t.Errorf("got %d routes, want 10", len(routes))
+	}
+}
+
+// TestMiddlewareChains checks the middleware resolved for each route,
+// outermost first, and that reports point at the unsafe middleware
+func TestMiddlewareChains(t *testing.T) {
+	results := analysistest.Run(t, testdataDir(t), analyzer.MiddlewareChainAnalyzer, "middlewarechain")
+	result := results[0].Pass.ResultOf[analyzer.Engine].(*analyzer.Result)
+
+	got := make(map[string]string)
+	for _, summary := range result.Handlers {
+		for _, route := range summary.Handler.Routes {
+			var names []string
+			for _, mw := range route.Middleware {
+				names = append(names, mw.Middleware.Name.Name)
+			}
+			got[route.String()] = strings.Join(names, " ")
+		}
 	}
+	want := map[string]string{
+		"GET /admin/users": "Auth Logging",
+		"/metrics":         "Logging",
+		"/limited":         "Logging RateLimit",
+		"/open":            "",
+		"/shared":          "",
+		"GET /api/orders":  "Logging Auth",
+		"GET /status":      "Logging",
+		"POST /upload":     "Logging RateLimit",
+		"GET /v2/items":    "Auth",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("middleware chains = %q, want %q", got, want)
+	}
+
+	for _, diag := range results[0].Diagnostics {
+		if strings.Contains(diag.Message, "httpx.") {
+			// Imported middleware is only known through its fact
+			continue
+		}
+		if len(diag.Related) == 0 || !strings.HasPrefix(diag.Related[0].Message, "Auth: ") && !strings.HasPrefix(diag.Related[0].Message, "RateLimit: ") {
+			t.Errorf("%q: related information %v does not point at the middleware", diag.Message, diag.Related)
+		}
+	}
+}
+
+// TestFacts checks the facts exported for response helpers and unsafe
+// middleware. The nextafterreject and middlewarechain fixtures import the
+// package to check that the facts are used across package boundaries.
+func TestFacts(t *testing.T) {
+	analysistest.Run(t, testdataDir(t), analyzer.Engine, "httpx")
 }
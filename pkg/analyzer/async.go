@@ -0,0 +1,253 @@
+package analyzer
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkGoroutineWrites reports uses of the tracked writer from goroutines
+// started by h. net/http forbids using the ResponseWriter once
+// ServeHTTP has returned. Goroutines the handler waits for before
+// returning, through a sync.WaitGroup or a channel they share, are not
+// reported.
+func checkGoroutineWrites(c *checker, h *handler) {
+	fn, aliases := h.Func, h.aliases
+	reported := make(map[token.Pos]bool)
+
+	forEachInstr(fn, func(instr ssa.Instruction) {
+		goInstr, ok := instr.(*ssa.Go)
+		if !ok {
+			return
+		}
+		common := goInstr.Common()
+		if joined(goInstr) {
+			return
+		}
+
+		if closure, ok := common.Value.(*ssa.MakeClosure); ok {
+			// A goroutine nested in another one is reported only once
+			if use := aliases.firstUse(closure.Fn.(*ssa.Function)); use.IsValid() && !reported[use] {
+				reported[use] = true
+				pos := use
+				if callExpr := c.index.calls[use]; callExpr != nil {
+					pos = callExpr.Pos()
+				}
+				c.reportf(ruleGoroutineWrite, pos, "ResponseWriter used in a goroutine that may outlive the handler")
+			}
+			return
+		}
+
+		if aliases.values[common.Value] || aliases.anyArg(common) {
+			c.reportf(ruleGoroutineWrite, goInstr.Pos(), "ResponseWriter passed to a goroutine that may outlive the handler")
+		}
+	})
+}
+
+// joined reports whether every path from the go statement goInstr to a
+// return of the enclosing function waits for the goroutine: it calls Wait
+// on a sync.WaitGroup, or receives from a channel, that the goroutine was
+// given. A Wait deferred before the go statement covers every return.
+func joined(goInstr *ssa.Go) bool {
+	shared := make(map[ssa.Value]bool)
+	common := goInstr.Common()
+	args := common.Args
+	if closure, ok := common.Value.(*ssa.MakeClosure); ok {
+		args = closure.Bindings
+	}
+	for _, arg := range args {
+		shared[joinValue(arg)] = true
+	}
+	isJoin := func(instr ssa.Instruction) bool {
+		switch instr := instr.(type) {
+		case *ssa.Call:
+			return isWaitGroupWait(instr.Common(), shared)
+		case *ssa.UnOp:
+			return instr.Op == token.ARROW && shared[joinValue(instr.X)]
+		}
+		return false
+	}
+
+	start := goInstr.Block()
+	index := -1
+	for i, instr := range start.Instrs {
+		if instr == goInstr {
+			index = i
+		}
+	}
+	for _, block := range goInstr.Parent().Blocks {
+		for i, instr := range block.Instrs {
+			d, ok := instr.(*ssa.Defer)
+			if ok && isWaitGroupWait(d.Common(), shared) && (block == start && i < index || block != start && block.Dominates(start)) {
+				return true
+			}
+		}
+	}
+
+	// Search the paths from the go statement for a return not preceded by a
+	// join; the rest of the starting block is scanned first.
+	seen := make(map[*ssa.BasicBlock]bool)
+	var reachesReturn func(block *ssa.BasicBlock, from int) bool
+	reachesReturn = func(block *ssa.BasicBlock, from int) bool {
+		for _, instr := range block.Instrs[from:] {
+			if isJoin(instr) {
+				return false
+			}
+			if _, ok := instr.(*ssa.Return); ok {
+				return true
+			}
+		}
+		for _, succ := range block.Succs {
+			if !seen[succ] {
+				seen[succ] = true
+				if reachesReturn(succ, 0) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return !reachesReturn(start, index+1)
+}
+
+// isWaitGroupWait reports whether call is a Wait on one of the shared
+// sync.WaitGroups
+func isWaitGroupWait(call *ssa.CallCommon, shared map[ssa.Value]bool) bool {
+	callee := call.StaticCallee()
+	if callee == nil || callee.Name() != "Wait" || len(call.Args) == 0 || !shared[joinValue(call.Args[0])] {
+		return false
+	}
+	recv := callee.Signature.Recv()
+	return recv != nil && recv.Type().String() == "*sync.WaitGroup"
+}
+
+// joinValue returns the variable a value is loaded from, so that a channel
+// variable captured by a goroutine and received from by the handler is
+// recognized as the same channel
+func joinValue(v ssa.Value) ssa.Value {
+	if load, ok := v.(*ssa.UnOp); ok && load.Op == token.MUL {
+		return load.X
+	}
+	return v
+}
+
+// checkDeferredWrites reports WriteHeader calls in functions deferred by the
+// handler when the handler body itself may already have written a response.
+// Such a late status is dropped by net/http with a "superfluous
+// response.WriteHeader call" warning.
+func checkDeferredWrites(c *checker, h *handler) {
+	fn, aliases := h.Func, h.aliases
+	if !aliases.writesResponse(fn) {
+		return
+	}
+
+	report := func(pos token.Pos) {
+		if callExpr := c.index.calls[pos]; callExpr != nil {
+			pos = callExpr.Pos()
+		}
+		c.reportf(ruleDeferredWrite, pos, "deferred WriteHeader may run after the handler has already written a status")
+	}
+
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			deferInstr, ok := instr.(*ssa.Defer)
+			if !ok {
+				continue
+			}
+			common := deferInstr.Common()
+
+			if aliases.isStatusWrite(common) {
+				report(common.Pos())
+				continue
+			}
+			if closure, ok := common.Value.(*ssa.MakeClosure); ok {
+				forEachInstr(closure.Fn.(*ssa.Function), func(instr ssa.Instruction) {
+					if call, ok := instr.(*ssa.Call); ok && aliases.isStatusWrite(call.Common()) {
+						report(call.Pos())
+					}
+				})
+			}
+		}
+	}
+}
+
+// writesResponse reports whether the body of fn, excluding deferred calls,
+// may write to the tracked writer: directly through a Write or WriteHeader
+// call, or by passing the writer to something like next.ServeHTTP.
+func (a *writerAliases) writesResponse(fn *ssa.Function) bool {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok {
+				continue
+			}
+			common := call.Common()
+			if recv := receiver(common); recv != nil && a.values[recv] {
+				switch methodName(common) {
+				case "WriteHeader", "Write", "WriteString", "ReadFrom":
+					return true
+				}
+				continue
+			}
+			// Wrapping the writer does not write to it
+			if a.anyArg(common) && !a.isWriter(call.Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// firstUse returns the position of the first call in fn, or in the closures
+// it creates, that uses the tracked writer as receiver or argument.
+func (a *writerAliases) firstUse(fn *ssa.Function) token.Pos {
+	first := token.NoPos
+	forEachInstr(fn, func(instr ssa.Instruction) {
+		call, ok := instr.(ssa.CallInstruction)
+		if !ok {
+			return
+		}
+		common := call.Common()
+		recv := receiver(common)
+		if (recv != nil && a.values[recv]) || a.anyArg(common) {
+			if pos := common.Pos(); !first.IsValid() || pos < first {
+				first = pos
+			}
+		}
+	})
+	return first
+}
+
+// anyArg reports whether the tracked writer is passed as an argument to call
+func (a *writerAliases) anyArg(call *ssa.CallCommon) bool {
+	for _, arg := range call.Args {
+		if a.values[arg] {
+			return true
+		}
+	}
+	return false
+}
+
+// methodName returns the name of the method called, or "" for function calls
+func methodName(call *ssa.CallCommon) string {
+	if call.IsInvoke() {
+		return call.Method.Name()
+	}
+	if callee := call.StaticCallee(); callee != nil && callee.Signature.Recv() != nil {
+		return callee.Name()
+	}
+	return ""
+}
+
+// forEachInstr calls f for every instruction of fn and of the function
+// literals nested in it.
+func forEachInstr(fn *ssa.Function, f func(ssa.Instruction)) {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			f(instr)
+		}
+	}
+	for _, anon := range fn.AnonFuncs {
+		forEachInstr(anon, f)
+	}
+}
@@ -0,0 +1,192 @@
+package analyzer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// synthetic describes a generated package of HTTP handlers, for benchmarks
+type synthetic struct {
+	// Middleware is the number of func(http.Handler) http.Handler
+	// constructors
+	Middleware int
+	// Handlers is the number of plain func(http.ResponseWriter,
+	// *http.Request) handlers
+	Handlers int
+	// Depth is how deeply handler literals are nested in each middleware
+	Depth int
+	// Stmts is the number of guarded WriteHeader statements in each handler
+	// body
+	Stmts int
+	// Violations is the number of middleware whose first WriteHeader is not
+	// followed by a return
+	Violations int
+}
+
+func (s synthetic) String() string {
+	return fmt.Sprintf("middleware=%d/handlers=%d/depth=%d/stmts=%d/violations=%d",
+		s.Middleware, s.Handlers, s.Depth, s.Stmts, s.Violations)
+}
+
+// perFile is the number of functions written to each generated file
+const perFile = 100
+
+// files returns the generated source files by name
+func (s synthetic) files() map[string]string {
+	var funcs []string
+	for m := 0; m < s.Middleware; m++ {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "\nfunc M%d(next http.Handler) http.Handler {\n", m)
+		sb.WriteString("return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {\n")
+		s.body(&sb, 1, m < s.Violations)
+		sb.WriteString("next.ServeHTTP(w, r)\n})\n}\n")
+		funcs = append(funcs, sb.String())
+	}
+	for h := 0; h < s.Handlers; h++ {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "\nfunc H%d(w http.ResponseWriter, r *http.Request) {\n", h)
+		// Plain handlers do not nest handler literals
+		s.body(&sb, s.Depth, false)
+		sb.WriteString("w.Write([]byte(\"ok\"))\n}\n")
+		funcs = append(funcs, sb.String())
+	}
+
+	files := make(map[string]string)
+	for i := 0; i < len(funcs); i += perFile {
+		end := min(i+perFile, len(funcs))
+		files[fmt.Sprintf("gen%03d.go", i/perFile)] = "package gen\n\nimport \"net/http\"\n" + strings.Join(funcs[i:end], "")
+	}
+	return files
+}
+
+// body writes the statements of a handler at nesting level, and the
+// handler literals nested in it
+func (s synthetic) body(sb *strings.Builder, level int, violation bool) {
+	for i := 0; i < s.Stmts; i++ {
+		fmt.Fprintf(sb, "if r.URL.Path == \"/%d/%d\" {\n", level, i)
+		sb.WriteString("w.Header().Set(\"X-Path\", r.URL.Path)\n")
+		sb.WriteString("w.WriteHeader(http.StatusNotFound)\n")
+		if !violation || i > 0 {
+			sb.WriteString("return\n")
+		}
+		sb.WriteString("}\n")
+	}
+	if level < s.Depth {
+		sb.WriteString("http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {\n")
+		s.body(sb, level+1, false)
+		sb.WriteString("}).ServeHTTP(w, r)\n")
+	}
+}
+
+// load writes the package as a module under a temporary directory and
+// type-checks it
+func (s synthetic) load(tb testing.TB) []*packages.Package {
+	dir := tb.TempDir()
+	files := s.files()
+	files["go.mod"] = "module gen\n\ngo 1.22\n"
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return loadPackages(tb, dir, ".")
+}
+
+// loadPackages type-checks the packages matching patterns in dir
+func loadPackages(tb testing.TB, dir string, patterns ...string) []*packages.Package {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax, Dir: dir}, patterns...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		tb.Fatal("packages do not type-check")
+	}
+	return pkgs
+}
+
+// analyze runs a over pkgs and returns the number of diagnostics
+func analyze(tb testing.TB, a *analysis.Analyzer, pkgs []*packages.Package) int {
+	graph, err := checker.Analyze([]*analysis.Analyzer{a}, pkgs, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	diagnostics := 0
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			tb.Fatal(act.Err)
+		}
+		diagnostics += len(act.Diagnostics)
+	}
+	return diagnostics
+}
+
+// TestSynthetic checks that generated packages type-check and contain
+// exactly the configured violations
+func TestSynthetic(t *testing.T) {
+	s := synthetic{Middleware: 150, Handlers: 20, Depth: 3, Stmts: 2, Violations: 7}
+	if got := analyze(t, analyzer.Analyzer, s.load(t)); got != s.Violations {
+		t.Errorf("%v: got %d diagnostics, want %d", s, got, s.Violations)
+	}
+}
+
+// benchmarks are the synthetic packages of BenchmarkAnalyzer and
+// BenchmarkEngine. The baseline is recorded in docs/benchmarks.md.
+var benchmarks = []synthetic{
+	{Middleware: 50, Handlers: 50, Depth: 1, Stmts: 5, Violations: 5},
+	{Middleware: 10, Handlers: 0, Depth: 8, Stmts: 50, Violations: 1},
+	{Middleware: 1000, Handlers: 1000, Depth: 1, Stmts: 5, Violations: 10},
+	{Middleware: 50, Handlers: 0, Depth: 8, Stmts: 200, Violations: 0},
+}
+
+// BenchmarkAnalyzer runs the returnafterstatus rule over synthetic packages
+func BenchmarkAnalyzer(b *testing.B) {
+	benchmarkSynthetic(b, analyzer.Analyzer)
+}
+
+// BenchmarkEngine runs every rule over synthetic packages
+func BenchmarkEngine(b *testing.B) {
+	benchmarkSynthetic(b, analyzer.Engine)
+}
+
+func benchmarkSynthetic(b *testing.B, a *analysis.Analyzer) {
+	for _, s := range benchmarks {
+		b.Run(s.String(), func(b *testing.B) {
+			pkgs := s.load(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				analyze(b, a, pkgs)
+			}
+			handlers := float64(s.Middleware*s.Depth + s.Handlers)
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/handlers, "ns/handler")
+		})
+	}
+}
+
+// BenchmarkCorpus runs every rule over the packages of a real codebase,
+// named by $RETURNLINTER_CORPUS as a directory and optional patterns, e.g.
+// RETURNLINTER_CORPUS="$HOME/src/service ./...".
+func BenchmarkCorpus(b *testing.B) {
+	corpus := strings.Fields(os.Getenv("RETURNLINTER_CORPUS"))
+	if len(corpus) == 0 {
+		b.Skip("RETURNLINTER_CORPUS is not set")
+	}
+	patterns := corpus[1:]
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgs := loadPackages(b, corpus[0], patterns...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		analyze(b, analyzer.Engine, pkgs)
+	}
+}
@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// checkMiddlewareChains reports every route of the inventory that runs
+// through a middleware with a return-after-status or next-after-reject
+// finding, and exports an UnsafeMiddlewareFact for such middleware when it
+// is exported. Middleware from other packages is known through those facts.
+// It must run after the per-handler checks.
+func checkMiddlewareChains(c *checker, inventory *Inventory) {
+	// The findings that make each middleware of the package unsafe
+	unsafe := make(map[types.Object][]Finding)
+	for _, finding := range c.result.Findings {
+		if finding.Rule != ruleReturnAfterStatus && finding.Rule != ruleNextAfterReject {
+			continue
+		}
+		for _, h := range inventory.Handlers {
+			if h.Kind == MiddlewareHandler && finding.Diagnostic.Pos >= h.Pos() && finding.Diagnostic.Pos < h.End() {
+				obj := c.pass.TypesInfo.Defs[h.Middleware.Name]
+				unsafe[obj] = append(unsafe[obj], finding)
+			}
+		}
+	}
+
+	for obj, findings := range unsafe {
+		if !obj.Exported() {
+			continue
+		}
+		fact := &UnsafeMiddlewareFact{}
+		for _, finding := range findings {
+			posn := c.pass.Fset.Position(finding.Diagnostic.Pos)
+			fact.Findings = append(fact.Findings, fmt.Sprintf("%s:%d: %s", posn.Filename, posn.Line, finding.Diagnostic.Message))
+		}
+		c.pass.ExportObjectFact(obj, fact)
+	}
+
+	for _, route := range inventory.Routes {
+		for _, fn := range route.Wrappers {
+			name := middlewareName(c.pass.Pkg, fn)
+			diag := analysis.Diagnostic{
+				Pos:     route.Pos,
+				Message: fmt.Sprintf("route %s runs through middleware %s, which continues after rejecting the request", route, name),
+			}
+
+			if fn.Pkg() != c.pass.Pkg {
+				var fact UnsafeMiddlewareFact
+				if !c.pass.ImportObjectFact(fn, &fact) {
+					continue
+				}
+				c.report(ruleMiddlewareChain, diag)
+				continue
+			}
+
+			findings := unsafe[fn]
+			if len(findings) == 0 {
+				continue
+			}
+			for _, finding := range findings {
+				diag.Related = append(diag.Related, analysis.RelatedInformation{
+					Pos:     finding.Diagnostic.Pos,
+					Message: fmt.Sprintf("%s: %s", name, finding.Diagnostic.Message),
+				})
+			}
+			c.report(ruleMiddlewareChain, diag)
+		}
+	}
+}
+
+// middlewareName returns how a middleware function is referred to from pkg,
+// e.g. "Auth" or "httpx.Auth"
+func middlewareName(pkg *types.Package, fn *types.Func) string {
+	if fn.Pkg() == nil || fn.Pkg() == pkg {
+		return fn.Name()
+	}
+	return fn.Pkg().Name() + "." + fn.Name()
+}
@@ -0,0 +1,202 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/types"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Config adapts the rules to a codebase. The zero Config is the default
+// behaviour of Engine and Analyzers.
+type Config struct {
+	// ResponseHelpers names functions that write the response status
+	// through their first http.ResponseWriter parameter, by their full name
+	// as printed by go/types, e.g. "example.com/api.JSONError" or
+	// "(*example.com/api.Responder).NotFound". Calls to them count as
+	// WriteHeader calls. Helpers in analyzed packages are recognized
+	// without configuration; this is for those the engine cannot see into,
+	// such as interface methods.
+	ResponseHelpers []string
+	// Loggers names the functions, or whole packages by import path, whose
+	// calls may come between a WriteHeader call and its return like a call
+	// into package log, e.g. "log/slog" or "(*go.uber.org/zap.Logger).Error".
+	Loggers []string
+	// UncheckedWriteErrorStatusOnly limits the uncheckedwrite rule to writes
+	// that may follow a WriteHeader call with a constant status of 400 or
+	// more, where a dropped write error hides a failed error response.
+	UncheckedWriteErrorStatusOnly bool
+	// ContentTypeHelpers names functions that set the Content-Type header
+	// of an http.ResponseWriter passed to them, by their full name as for
+	// ResponseHelpers. For the contenttype rule, calling one counts as
+	// setting the header.
+	ContentTypeHelpers []string
+	// StatusPolicies restrict the constant statuses that handlers write,
+	// for the statuspolicy rule, which reports nothing without them.
+	StatusPolicies []StatusPolicy
+}
+
+// ConfigFlags holds the command-line flags that set a Config, shared by the
+// returnlinter commands
+type ConfigFlags struct {
+	responseHelpers    string
+	loggers            string
+	errorStatusOnly    bool
+	contentTypeHelpers string
+	policyFile         string
+}
+
+// Register defines the configuration flags in flags
+func (f *ConfigFlags) Register(flags *flag.FlagSet) {
+	flags.StringVar(&f.responseHelpers, "response-helpers", "", "comma-separated full names of functions that write a response status, e.g. example.com/api.JSONError")
+	flags.StringVar(&f.loggers, "loggers", "", "comma-separated packages or functions whose calls may come between WriteHeader and return, e.g. log/slog")
+	flags.BoolVar(&f.errorStatusOnly, "uncheckedwrite-error-status", false, "only report uncheckedwrite findings for writes that may follow a constant error status of 400 or more")
+	flags.StringVar(&f.contentTypeHelpers, "content-type-helpers", "", "comma-separated full names of functions that set the Content-Type header of a ResponseWriter, e.g. example.com/api.SetJSON")
+	flags.StringVar(&f.policyFile, "status-policies", "", "JSON file holding an array of status policies for the statuspolicy rule")
+}
+
+// Config returns the Config set by the parsed flags, reading the status
+// policies file if one was given. The Config is validated by New.
+func (f *ConfigFlags) Config() (Config, error) {
+	policies, err := readPolicies(f.policyFile)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{
+		ResponseHelpers:               splitList(f.responseHelpers),
+		Loggers:                       splitList(f.loggers),
+		UncheckedWriteErrorStatusOnly: f.errorStatusOnly,
+		ContentTypeHelpers:            splitList(f.contentTypeHelpers),
+		StatusPolicies:                policies,
+	}, nil
+}
+
+// readPolicies reads the JSON array of status policies in the file name,
+// or returns nil if name is empty
+func readPolicies(name string) ([]StatusPolicy, error) {
+	if name == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var policies []StatusPolicy
+	if err := dec.Decode(&policies); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return policies, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty elements
+func splitList(value string) []string {
+	var list []string
+	for _, elem := range strings.Split(value, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
+
+// Linter is an Engine and the analyzers of its rules, sharing one Config
+type Linter struct {
+	Engine *analysis.Analyzer
+	// Analyzers lists the analyzer of every rule, in the same order as
+	// Rules
+	Analyzers []*analysis.Analyzer
+}
+
+// New returns a Linter configured by cfg. Its analyzers have the same names
+// as the package's default ones, so a run should not mix the two.
+func New(cfg Config) (*Linter, error) {
+	conf, err := newConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	engine := newEngine(conf)
+	linter := &Linter{Engine: engine}
+	for _, rule := range Rules {
+		linter.Analyzers = append(linter.Analyzers, newRule(engine, rule))
+	}
+	return linter, nil
+}
+
+// config is the parsed form of Config
+type config struct {
+	helpers map[string]bool
+	loggers map[string]bool
+	// contentTypeHelpers holds Config.ContentTypeHelpers
+	contentTypeHelpers map[string]bool
+	// uncheckedErrorStatusOnly is Config.UncheckedWriteErrorStatusOnly
+	uncheckedErrorStatusOnly bool
+	policies                 []*statusPolicy
+}
+
+func newConfig(cfg Config) (*config, error) {
+	conf := &config{
+		helpers:                  make(map[string]bool),
+		loggers:                  make(map[string]bool),
+		contentTypeHelpers:       make(map[string]bool),
+		uncheckedErrorStatusOnly: cfg.UncheckedWriteErrorStatusOnly,
+	}
+	for _, name := range cfg.ResponseHelpers {
+		if !isFuncName(name) {
+			return nil, fmt.Errorf("response helper %q: want a function name such as example.com/api.JSONError", name)
+		}
+		conf.helpers[name] = true
+	}
+	for _, name := range cfg.ContentTypeHelpers {
+		if !isFuncName(name) {
+			return nil, fmt.Errorf("content type helper %q: want a function name such as example.com/api.SetJSON", name)
+		}
+		conf.contentTypeHelpers[name] = true
+	}
+	for _, p := range cfg.StatusPolicies {
+		policy, err := newStatusPolicy(p)
+		if err != nil {
+			return nil, err
+		}
+		conf.policies = append(conf.policies, policy)
+	}
+	for _, name := range cfg.Loggers {
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("logger %q: want a package path or function name such as log/slog", name)
+		}
+		conf.loggers[name] = true
+	}
+	return conf, nil
+}
+
+// isFuncName reports whether name looks like the full name of a function,
+// which has a dot after any slash of its package path
+func isFuncName(name string) bool {
+	return !strings.ContainsAny(name, " \t") && strings.Contains(name[strings.LastIndex(name, "/")+1:], ".")
+}
+
+// isHelper reports whether fn is a configured response helper
+func (c *config) isHelper(fn *types.Func) bool {
+	return c.helpers[fn.Origin().FullName()]
+}
+
+// isContentTypeHelper reports whether fn is a configured Content-Type
+// helper
+func (c *config) isContentTypeHelper(fn *types.Func) bool {
+	return c.contentTypeHelpers[fn.Origin().FullName()]
+}
+
+// isLogger reports whether fn is a configured logger or belongs to a
+// configured logging package
+func (c *config) isLogger(fn *types.Func) bool {
+	if c.loggers[fn.Origin().FullName()] {
+		return true
+	}
+	return fn.Pkg() != nil && c.loggers[fn.Pkg().Path()]
+}
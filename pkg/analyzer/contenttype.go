@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"go/constant"
+	"go/types"
+	"net/textproto"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkContentType reports WriteHeader calls through the tracked writer of
+// h with a constant error status that some path follows with a body write,
+// unless the Content-Type header is set on every path to them. Each
+// function of the handler is checked on its own, so the header must be set
+// in the same function as the status.
+func checkContentType(c *checker, h *handler) {
+	aliases := h.aliases
+	var walk func(fn *ssa.Function)
+	walk = func(fn *ssa.Function) {
+		for _, block := range fn.Blocks {
+			for i, instr := range block.Instrs {
+				if !aliases.writesErrorStatus(instr) {
+					continue
+				}
+				body := aliases.bodyWriteAfter(block, i)
+				if body == nil || c.contentTypeSetBefore(aliases, block, i) {
+					continue
+				}
+				callExpr := c.index.calls[instr.(*ssa.Call).Pos()]
+				if callExpr == nil {
+					continue
+				}
+				diag := analysis.Diagnostic{
+					Pos:     callExpr.Pos(),
+					Message: "error status written without setting the Content-Type header of the body that follows",
+				}
+				if bodyExpr := c.index.calls[body.Pos()]; bodyExpr != nil {
+					diag.Related = []analysis.RelatedInformation{{
+						Pos:     bodyExpr.Pos(),
+						End:     bodyExpr.End(),
+						Message: "response body written here",
+					}}
+				}
+				c.report(ruleContentType, diag)
+			}
+		}
+		for _, anon := range fn.AnonFuncs {
+			walk(anon)
+		}
+	}
+	walk(h.Func)
+}
+
+// bodyWriteAfter returns the first body write found on a path from the
+// instruction at index i of block, or nil if there is none
+func (a *writerAliases) bodyWriteAfter(block *ssa.BasicBlock, i int) *ssa.Call {
+	isWrite := func(instr ssa.Instruction) *ssa.Call {
+		if call, ok := instr.(*ssa.Call); ok {
+			if _, ok := a.bodyWrite(call.Common()); ok {
+				return call
+			}
+		}
+		return nil
+	}
+	for _, instr := range block.Instrs[i+1:] {
+		if call := isWrite(instr); call != nil {
+			return call
+		}
+	}
+
+	seen := make(map[*ssa.BasicBlock]bool)
+	worklist := append([]*ssa.BasicBlock(nil), block.Succs...)
+	for len(worklist) > 0 {
+		b := worklist[0]
+		worklist = worklist[1:]
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		for _, instr := range b.Instrs {
+			if call := isWrite(instr); call != nil {
+				return call
+			}
+		}
+		worklist = append(worklist, b.Succs...)
+	}
+	return nil
+}
+
+// contentTypeSetBefore reports whether the Content-Type header of the
+// tracked writer is set on every path reaching the instruction at index i
+// of block. This holds when a single Set dominates the instruction, and
+// also when each branch sets the header on its own.
+func (c *checker) contentTypeSetBefore(a *writerAliases, block *ssa.BasicBlock, i int) bool {
+	for _, instr := range block.Instrs[:i] {
+		if c.setsContentType(a, instr) {
+			return true
+		}
+	}
+
+	fn := block.Parent()
+	sets := make(map[*ssa.BasicBlock]bool)
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if c.setsContentType(a, instr) {
+				sets[b] = true
+				break
+			}
+		}
+	}
+	// entry[b] is whether the header is set on every path reaching b,
+	// refined from true down to a fixed point
+	entry := make(map[*ssa.BasicBlock]bool)
+	for _, b := range fn.Blocks {
+		entry[b] = len(b.Preds) > 0
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			if !entry[b] {
+				continue
+			}
+			for _, pred := range b.Preds {
+				if !entry[pred] && !sets[pred] {
+					entry[b] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return entry[block]
+}
+
+// setsContentType reports whether instr sets the Content-Type header of the
+// tracked writer: w.Header().Set("Content-Type", ...), an index assignment
+// to w.Header(), or a call passing the writer to a configured Content-Type
+// helper
+func (c *checker) setsContentType(a *writerAliases, instr ssa.Instruction) bool {
+	switch instr := instr.(type) {
+	case *ssa.MapUpdate:
+		// net/http only reads canonical keys from the map
+		return a.isHeader(instr.Map) && isContentTypeKey(instr.Key, false)
+	case *ssa.Call:
+		common := instr.Common()
+		var fn *types.Func
+		if common.IsInvoke() {
+			fn = common.Method
+		} else if callee := common.StaticCallee(); callee != nil {
+			fn, _ = callee.Object().(*types.Func)
+		}
+		if fn == nil {
+			return false
+		}
+		if c.config.isContentTypeHelper(fn) {
+			for _, arg := range common.Args {
+				if a.refersTo(arg) {
+					return true
+				}
+			}
+			return false
+		}
+		// http.Header.Set is a static call with the header first
+		return fn.Name() == "Set" && isHTTPHeaderMethod(fn) && len(common.Args) == 3 &&
+			a.isHeader(common.Args[0]) && isContentTypeKey(common.Args[1], true)
+	}
+	return false
+}
+
+// isHeader reports whether v is the result of a Header call on the tracked
+// writer
+func (a *writerAliases) isHeader(v ssa.Value) bool {
+	call, ok := v.(*ssa.Call)
+	if !ok {
+		return false
+	}
+	recv := receiver(call.Common())
+	return recv != nil && a.values[recv] && methodName(call.Common()) == "Header"
+}
+
+// isHTTPHeaderMethod reports whether fn is a method of net/http.Header
+func isHTTPHeaderMethod(fn *types.Func) bool {
+	recv := fn.Signature().Recv()
+	if recv == nil {
+		return false
+	}
+	named, ok := types.Unalias(recv.Type()).(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "net/http" && named.Obj().Name() == "Header"
+}
+
+// isContentTypeKey reports whether v is a constant header key naming
+// Content-Type, in any case if canonicalize is set
+func isContentTypeKey(v ssa.Value, canonicalize bool) bool {
+	k, ok := v.(*ssa.Const)
+	if !ok || k.Value == nil || k.Value.Kind() != constant.String {
+		return false
+	}
+	key := constant.StringVal(k.Value)
+	if canonicalize {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+	}
+	return key == "Content-Type"
+}
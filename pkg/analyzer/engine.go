@@ -0,0 +1,264 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// checkReturnAfterStatus reports every status write through the tracked
+// writer of a middleware handler that is not immediately followed by an
+// explicit return.
+//
+// Each WriteHeader call of the package is considered once, in the handler
+// whose body contains it. It is attributed to the innermost middleware
+// handler whose writer it writes to: a handler literal nested in another may
+// write to the outer writer it captures.
+//
+// The check runs over the SSA control-flow graph, so every statement kind
+// (labelled loops, select clauses, goto targets, ...) is covered by the same
+// code. Starting after the WriteHeader call, control is followed along
+// unconditional jumps; the path is accepted if it reaches an explicit return
+// having executed nothing but a single optional log call. Only a goto or
+// fallthrough may take control out of the call's own block first: a return
+// after the end of the block, which a jump reaches just the same, does not
+// count.
+func checkReturnAfterStatus(c *checker, handlers []*handler) {
+	byFunc := make(map[*ssa.Function]*handler)
+	for _, h := range handlers {
+		byFunc[h.Func] = h
+	}
+
+	// The WriteHeader call instructions in the handlers' own bodies
+	type site struct {
+		block *ssa.BasicBlock
+		index int
+	}
+	sites := make(map[token.Pos]site)
+	for _, h := range handlers {
+		for _, block := range h.Func.Blocks {
+			for i, instr := range block.Instrs {
+				if call, ok := instr.(*ssa.Call); ok && isStatusMethodCall(call.Common()) {
+					sites[call.Pos()] = site{block, i}
+				}
+			}
+		}
+	}
+
+	for _, callExpr := range c.index.statusCalls {
+		site, ok := sites[callExpr.Lparen]
+		if !ok {
+			continue
+		}
+		fn := site.block.Parent()
+		call := site.block.Instrs[site.index].(*ssa.Call)
+
+		var owner *handler
+		for enclosing := fn; enclosing != nil && owner == nil; enclosing = enclosing.Parent() {
+			if h := byFunc[enclosing]; h != nil && h.Kind == MiddlewareHandler && h.aliases.isStatusWrite(call.Common()) {
+				owner = h
+			}
+		}
+		if owner == nil {
+			continue
+		}
+		// Informational statuses are followed by the real response
+		if code, ok := statusArg(call.Common()); ok && code < 200 {
+			continue
+		}
+		next := c.continuesAfter(site.block, site.index)
+		end, leaves := c.index.blockEnds[callExpr]
+		if next == nil && !leaves {
+			continue
+		}
+
+		diag := analysis.Diagnostic{
+			Pos:     callExpr.Pos(),
+			End:     callExpr.End(),
+			Message: "WriteHeader call not immediately followed by return statement",
+		}
+		if next == nil {
+			diag.Related = append(diag.Related, analysis.RelatedInformation{
+				Pos:     end,
+				Message: "the block ends here without a return statement",
+			})
+		} else if related, ok := continuation(fn, next, c.index.calls); ok {
+			diag.Related = append(diag.Related, related)
+		}
+		diag.Related = append(diag.Related, analysis.RelatedInformation{
+			Pos:     owner.Middleware.Name.Pos(),
+			End:     owner.Middleware.Name.End(),
+			Message: "in middleware " + owner.Middleware.Name.Name,
+		})
+		c.report(ruleReturnAfterStatus, diag)
+	}
+}
+
+// isStatusWrite reports whether call is a WriteHeader call on the writer
+func (a *writerAliases) isStatusWrite(call *ssa.CallCommon) bool {
+	return isStatusMethodCall(call) && a.values[receiver(call)]
+}
+
+// continuesAfter follows control leaving instruction i of block and returns
+// nil if it reaches an explicit return statement without any intervening side
+// effect other than one log call. Otherwise it returns the instruction where
+// execution carries on: the first extra side effect, the branch or loop that
+// is reached instead of a return, or the implicit return at the end of the
+// handler.
+func (c *checker) continuesAfter(block *ssa.BasicBlock, i int) ssa.Instruction {
+	var effects []ssa.Instruction
+	visited := map[*ssa.BasicBlock]bool{block: true}
+
+	instrs := block.Instrs[i+1:]
+	for {
+		for _, instr := range instrs {
+			switch instr := instr.(type) {
+			case *ssa.Return:
+				if extra := c.extraEffect(effects); extra != nil {
+					return extra
+				}
+				// Falling off the end of the handler has no position
+				if !instr.Pos().IsValid() {
+					return instr
+				}
+				return nil
+			case *ssa.Panic, *ssa.If:
+				// Only a literal panic(...) is an *ssa.Panic, which is not
+				// a return either. log.Fatal and log.Panic are ordinary
+				// calls and are followed like any other.
+				if extra := c.extraEffect(effects); extra != nil {
+					return extra
+				}
+				return instr
+			case *ssa.Jump:
+				// Followed below, it is always the last instruction
+			default:
+				if hasEffect(instr) {
+					effects = append(effects, instr)
+				}
+			}
+		}
+
+		next := block.Succs[0]
+		if visited[next] {
+			if extra := c.extraEffect(effects); extra != nil {
+				return extra
+			}
+			return block.Instrs[len(block.Instrs)-1]
+		}
+		visited[next] = true
+		block, instrs = next, next.Instrs
+	}
+}
+
+// extraEffect returns nil if effects are empty or amount to a single log
+// call, including the evaluation of its arguments. Otherwise it returns the
+// first effect that is not part of that log call.
+func (c *checker) extraEffect(effects []ssa.Instruction) ssa.Instruction {
+	calls := c.index.calls
+	var logCall *ast.CallExpr
+	for _, effect := range effects {
+		if call, ok := effect.(*ssa.Call); ok {
+			if callExpr := calls[call.Pos()]; callExpr != nil && c.isLogCall(callExpr) {
+				logCall = callExpr
+				break
+			}
+		}
+	}
+
+	for _, effect := range effects {
+		if logCall == nil {
+			return effect
+		}
+		if pos := effect.Pos(); pos < logCall.Pos() || pos >= logCall.End() {
+			return effect
+		}
+		if call, ok := effect.(*ssa.Call); ok && calls[call.Pos()] == logCall {
+			// Anything after the log call is extra
+			logCall = nil
+		}
+	}
+	return nil
+}
+
+// isLogCall reports whether callExpr calls into package log or a configured
+// logger
+func (c *checker) isLogCall(callExpr *ast.CallExpr) bool {
+	if isLogCall(callExpr) {
+		return true
+	}
+	fn, ok := typeutil.Callee(c.pass.TypesInfo, callExpr).(*types.Func)
+	return ok && c.config.isLogger(fn)
+}
+
+// continuation describes where execution carries on after a WriteHeader call
+// that is not followed by a return, for the diagnostic's related information.
+// It returns false if the instruction has no usable source position.
+func continuation(fn *ssa.Function, instr ssa.Instruction, calls map[token.Pos]*ast.CallExpr) (analysis.RelatedInformation, bool) {
+	switch instr := instr.(type) {
+	case *ssa.Call:
+		if callExpr := calls[instr.Pos()]; callExpr != nil {
+			return analysis.RelatedInformation{
+				Pos:     callExpr.Pos(),
+				End:     callExpr.End(),
+				Message: "execution continues with a call to " + types.ExprString(callExpr.Fun),
+			}, true
+		}
+	case *ssa.Return:
+		if body := funcBody(fn.Syntax()); body != nil {
+			return analysis.RelatedInformation{
+				Pos:     body.Rbrace,
+				End:     body.Rbrace + 1,
+				Message: "the handler ends here without a return statement",
+			}, true
+		}
+	case *ssa.If:
+		if cond := instr.Cond; cond.Pos().IsValid() {
+			return analysis.RelatedInformation{Pos: cond.Pos(), Message: "execution continues with a branch"}, true
+		}
+	case *ssa.Go:
+		return analysis.RelatedInformation{Pos: instr.Pos(), Message: "execution continues with a go statement"}, true
+	case *ssa.Defer:
+		return analysis.RelatedInformation{Pos: instr.Pos(), Message: "execution continues with a defer statement"}, true
+	default:
+		if pos := instr.Pos(); pos.IsValid() {
+			return analysis.RelatedInformation{Pos: pos, Message: "execution continues here"}, true
+		}
+	}
+	return analysis.RelatedInformation{}, false
+}
+
+// funcBody returns the body of a function declaration or literal
+func funcBody(syntax ast.Node) *ast.BlockStmt {
+	switch fn := syntax.(type) {
+	case *ast.FuncDecl:
+		return fn.Body
+	case *ast.FuncLit:
+		return fn.Body
+	}
+	return nil
+}
+
+// hasEffect reports whether instr does something observable beyond computing
+// a value, and so counts as a statement executed after WriteHeader.
+func hasEffect(instr ssa.Instruction) bool {
+	switch instr := instr.(type) {
+	case *ssa.Call, *ssa.Go, *ssa.Defer, *ssa.Send, *ssa.MapUpdate, *ssa.Select:
+		return true
+	case *ssa.Store:
+		// Filling in the variadic arguments of a call belongs to that call
+		if index, ok := instr.Addr.(*ssa.IndexAddr); ok {
+			if alloc, ok := index.X.(*ssa.Alloc); ok && alloc.Comment == "varargs" {
+				return false
+			}
+		}
+		return true
+	case *ssa.UnOp:
+		return instr.Op == token.ARROW
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ssa"
+)
+
+// WritesStatusFact is exported for a function that writes the response
+// status through one of its http.ResponseWriter parameters on every path
+// that returns normally, such as a JSON error helper. Packages calling the
+// function treat the call like a WriteHeader call.
+type WritesStatusFact struct {
+	// Param is the index of the ResponseWriter among the function's
+	// parameters, not counting any receiver
+	Param int
+}
+
+func (*WritesStatusFact) AFact() {}
+
+func (f *WritesStatusFact) String() string {
+	return fmt.Sprintf("writesStatus(%d)", f.Param)
+}
+
+// UnsafeMiddlewareFact is exported for a func(http.Handler) http.Handler
+// middleware whose handler continues after rejecting a request, so that
+// routes wrapped by it in other packages are reported by the
+// middlewarechain rule.
+type UnsafeMiddlewareFact struct {
+	// Findings are the messages of the middleware's findings, prefixed with
+	// their positions
+	Findings []string
+}
+
+func (*UnsafeMiddlewareFact) AFact() {}
+
+func (f *UnsafeMiddlewareFact) String() string {
+	return "unsafeMiddleware"
+}
+
+// statusHelpers finds the functions that always write a status. Configured
+// helpers are taken at their word. Other functions of the package are
+// analyzed on demand; imported ones are looked up in the facts of their
+// package.
+type statusHelpers struct {
+	pass   *analysis.Pass
+	config *config
+	prog   *ssa.Program
+	// params caches the ResponseWriter parameter index of every function
+	// of the package analyzed so far, -1 for those that are not helpers
+	params map[*types.Func]int
+}
+
+// writerParam returns the index of the ResponseWriter parameter through
+// which fn always writes a status
+func (s *statusHelpers) writerParam(fn *types.Func) (int, bool) {
+	if s.config.isHelper(fn) {
+		params := fn.Signature().Params()
+		for i := 0; i < params.Len(); i++ {
+			if isResponseWriterType(params.At(i).Type()) {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	if fn.Pkg() != s.pass.Pkg {
+		var fact WritesStatusFact
+		if s.pass.ImportObjectFact(fn, &fact) {
+			return fact.Param, true
+		}
+		return 0, false
+	}
+
+	if param, ok := s.params[fn]; ok {
+		return param, param >= 0
+	}
+	// Recursive calls are assumed not to write until proven otherwise
+	s.params[fn] = -1
+
+	ssaFn := s.prog.FuncValue(fn)
+	if ssaFn == nil || len(ssaFn.Blocks) == 0 {
+		return 0, false
+	}
+	param := -1
+	for i := 0; i < fn.Signature().Params().Len(); i++ {
+		if !isResponseWriterType(fn.Signature().Params().At(i).Type()) {
+			continue
+		}
+		aliases := trackWriterParam(ssaFn, i)
+		aliases.helpers = s
+		if aliases.alwaysWritesStatus(ssaFn) {
+			param = i
+			break
+		}
+	}
+	s.params[fn] = param
+	return param, param >= 0
+}
+
+// exportFacts records a WritesStatusFact for every exported helper among fns
+func (s *statusHelpers) exportFacts(fns []*ssa.Function) {
+	for _, fn := range fns {
+		obj, ok := fn.Object().(*types.Func)
+		if !ok || !obj.Exported() || fn.TypeParams().Len() > 0 {
+			continue
+		}
+		if param, ok := s.writerParam(obj); ok {
+			s.pass.ExportObjectFact(obj, &WritesStatusFact{Param: param})
+		}
+	}
+}
+
+// helperWrite reports whether call passes the tracked writer to a function
+// that always writes a status through it
+func (a *writerAliases) helperWrite(call *ssa.CallCommon) bool {
+	if a.helpers == nil {
+		return false
+	}
+	var obj *types.Func
+	if call.IsInvoke() {
+		// Only configured helpers can be interface methods
+		obj = call.Method
+	} else if callee := call.StaticCallee(); callee != nil {
+		obj, _ = callee.Object().(*types.Func)
+	}
+	if obj == nil {
+		return false
+	}
+	param, ok := a.helpers.writerParam(obj)
+	if !ok {
+		return false
+	}
+	if !call.IsInvoke() && obj.Signature().Recv() != nil {
+		// Static method calls pass the receiver first
+		param++
+	}
+	return param < len(call.Args) && a.values[call.Args[param]]
+}
+
+// alwaysWritesStatus reports whether every path through fn that returns
+// normally writes a status through the tracked writer
+func (a *writerAliases) alwaysWritesStatus(fn *ssa.Function) bool {
+	writes := make(map[*ssa.BasicBlock]bool)
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			if a.writesStatus(instr) {
+				writes[block] = true
+				break
+			}
+		}
+	}
+
+	// Greatest fixpoint of: written on exit from a block if the block
+	// writes or the status is written on exit from all its predecessors
+	written := make(map[*ssa.BasicBlock]bool)
+	for _, block := range fn.Blocks {
+		written[block] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, block := range fn.Blocks {
+			if !written[block] || writes[block] {
+				continue
+			}
+			onEntry := len(block.Preds) > 0
+			for _, pred := range block.Preds {
+				onEntry = onEntry && written[pred]
+			}
+			if !onEntry {
+				written[block] = false
+				changed = true
+			}
+		}
+	}
+
+	returns := false
+	for _, block := range fn.Blocks {
+		if len(block.Instrs) == 0 {
+			continue
+		}
+		if _, ok := block.Instrs[len(block.Instrs)-1].(*ssa.Return); ok {
+			if !written[block] {
+				return false
+			}
+			returns = true
+		}
+	}
+	return returns
+}
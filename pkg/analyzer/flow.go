@@ -0,0 +1,181 @@
+package analyzer
+
+import (
+	"go/constant"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkDoubleWrite reports WriteHeader calls through the tracked writer of h
+// that may run after the response status was already written, either by an
+// earlier WriteHeader or implicitly by a Write.
+func checkDoubleWrite(c *checker, h *handler) {
+	h.aliases.walkStatusFlow(h.Func, func(instr ssa.Instruction, written bool) {
+		call, ok := instr.(*ssa.Call)
+		if !ok || !written || !h.aliases.isStatusWrite(call.Common()) {
+			return
+		}
+		if callExpr := c.index.calls[call.Pos()]; callExpr != nil {
+			c.reportf(ruleDoubleWrite, callExpr.Pos(), "WriteHeader may be called after the response status was already written")
+		}
+	})
+}
+
+// checkHeaderAfterWrite reports header modifications through the tracked
+// writer of h that may happen after the status was written, when net/http
+// has already sent the headers.
+func checkHeaderAfterWrite(c *checker, h *handler) {
+	h.aliases.walkStatusFlow(h.Func, func(instr ssa.Instruction, written bool) {
+		call, ok := instr.(*ssa.Call)
+		if !ok || !written {
+			return
+		}
+		common := call.Common()
+		recv := receiver(common)
+		if recv == nil || !h.aliases.values[recv] || methodName(common) != "Header" || !modifiesHeader(call) {
+			return
+		}
+		if callExpr := c.index.calls[call.Pos()]; callExpr != nil {
+			c.reportf(ruleHeaderAfterWrite, callExpr.Pos(), "response header modified after the status was written; the change has no effect")
+		}
+	})
+}
+
+// checkNextAfterReject reports middleware that passes the tracked writer of h
+// to a ServeHTTP call after a status may already have been written.
+func checkNextAfterReject(c *checker, h *handler) {
+	h.aliases.walkStatusFlow(h.Func, func(instr ssa.Instruction, written bool) {
+		call, ok := instr.(*ssa.Call)
+		if !ok || !written {
+			return
+		}
+		common := call.Common()
+		if methodName(common) != "ServeHTTP" || !h.aliases.anyArg(common) {
+			return
+		}
+		if callExpr := c.index.calls[call.Pos()]; callExpr != nil {
+			c.reportf(ruleNextAfterReject, callExpr.Pos(), "next handler called after the response status was already written")
+		}
+	})
+}
+
+// modifiesHeader reports whether the http.Header returned by call is
+// modified through Set, Add, Del or an index assignment.
+func modifiesHeader(call *ssa.Call) bool {
+	for _, instr := range *call.Referrers() {
+		switch instr := instr.(type) {
+		case *ssa.MapUpdate:
+			if instr.Map == call {
+				return true
+			}
+		case ssa.CallInstruction:
+			common := instr.Common()
+			if callee := common.StaticCallee(); callee != nil && callee.Signature.Recv() != nil {
+				switch callee.Name() {
+				case "Set", "Add", "Del":
+					return len(common.Args) > 0 && common.Args[0] == call
+				}
+			}
+		}
+	}
+	return false
+}
+
+// walkStatusFlow calls visit for every instruction of fn together with
+// whether a final status may already have been written through the tracked
+// writer when the instruction runs.
+func (a *writerAliases) walkStatusFlow(fn *ssa.Function, visit func(instr ssa.Instruction, written bool)) {
+	walkFlow(fn, a.writesStatus, visit)
+}
+
+// walkFlow calls visit for every instruction of fn together with whether
+// some path reaching the instruction has run an instruction matching gen.
+func walkFlow(fn *ssa.Function, gen func(ssa.Instruction) bool, visit func(instr ssa.Instruction, reached bool)) {
+	entry := reachedOnEntry(fn, gen)
+	for _, block := range fn.Blocks {
+		reached := entry[block]
+		for _, instr := range block.Instrs {
+			visit(instr, reached)
+			if gen(instr) {
+				reached = true
+			}
+		}
+	}
+}
+
+// reachedOnEntry computes, for every block of fn, whether some path reaching
+// it has run an instruction matching gen.
+func reachedOnEntry(fn *ssa.Function, gen func(ssa.Instruction) bool) map[*ssa.BasicBlock]bool {
+	gens := make(map[*ssa.BasicBlock]bool)
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			if gen(instr) {
+				gens[block] = true
+				break
+			}
+		}
+	}
+
+	entry := make(map[*ssa.BasicBlock]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, block := range fn.Blocks {
+			if entry[block] {
+				continue
+			}
+			for _, pred := range block.Preds {
+				if entry[pred] || gens[pred] {
+					entry[block] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return entry
+}
+
+// writesStatus reports whether instr writes the final response status
+// through the tracked writer. Writing a body writes an implicit 200, calling
+// a helper that always writes a status counts, and informational 1xx codes
+// do not.
+func (a *writerAliases) writesStatus(instr ssa.Instruction) bool {
+	call, ok := instr.(*ssa.Call)
+	if !ok {
+		return false
+	}
+	common := call.Common()
+	if a.helperWrite(common) {
+		return true
+	}
+	recv := receiver(common)
+	if recv == nil || !a.values[recv] {
+		return false
+	}
+
+	switch methodName(common) {
+	case "WriteHeader":
+		code, ok := statusArg(common)
+		return !ok || code >= 200
+	case "Write", "WriteString", "ReadFrom":
+		return true
+	}
+	return false
+}
+
+// statusArg returns the status code of a WriteHeader call if it is constant
+func statusArg(call *ssa.CallCommon) (int64, bool) {
+	args := call.Args
+	if !call.IsInvoke() && len(args) > 0 {
+		// Static method calls pass the receiver first
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return 0, false
+	}
+	c, ok := args[0].(*ssa.Const)
+	if !ok || c.Value == nil || c.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(c.Value)
+}
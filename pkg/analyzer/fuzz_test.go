@@ -0,0 +1,518 @@
+package analyzer_test
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+)
+
+// analyzeSource runs a over src with analyzer.RunSource and returns its
+// diagnostics. It fails the test if src does not type-check.
+func analyzeSource(tb testing.TB, a *analysis.Analyzer, src string) ([]analysis.Diagnostic, *token.FileSet) {
+	tb.Helper()
+	res, err := analyzer.RunSource(a, src)
+	if err != nil {
+		tb.Fatalf("%v\n%s", err, src)
+	}
+	return res.Diagnostics, res.Fset
+}
+
+// FuzzReturnAfterStatus generates middleware bodies from a grammar of
+// statements and checks that the returnafterstatus rule agrees with a
+// reference interpreter on which WriteHeader calls are not followed by a
+// return.
+func FuzzReturnAfterStatus(f *testing.F) {
+	f.Add([]byte{0, 2})
+	f.Add([]byte{3, 0, 1, 2})
+	f.Add([]byte{1, 9, 2, 0, 12, 5})
+	f.Add([]byte{2, 6, 1, 0, 2, 1, 1, 0, 1, 4})
+	f.Add([]byte{4, 7, 2, 0, 2, 0, 1, 1, 0, 11, 0, 2})
+	f.Add([]byte{3, 10, 1, 0, 13, 8, 2, 0, 1, 14, 1, 0, 2})
+	f.Add([]byte{5, 15, 2, 0, 11, 3, 9, 1, 0, 12, 2, 0, 1, 2})
+	f.Add([]byte{1, 7, 0, 0, 0, 2})
+	f.Add([]byte{1, 0, 15, 0, 2})
+	f.Add([]byte{5, 15, 2, 0, 11, 39, 127, 97, 0, 12, 89, 0, 48, 50})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		prog := generateProgram(data)
+		src, lines := prog.render()
+
+		diagnostics, fset := analyzeSource(t, analyzer.Analyzer, src)
+		got := make(map[int]bool)
+		for _, diag := range diagnostics {
+			got[lines[fset.Position(diag.Pos).Line]] = true
+		}
+		want := prog.violations()
+
+		for id := 0; id < prog.writes; id++ {
+			if got[id] != want[id] {
+				t.Errorf("WriteHeader #%d: reported = %v, reference interpreter says %v\n%s", id, got[id], want[id], numbered(src))
+			}
+		}
+	})
+}
+
+// numbered prefixes each line of src with its number
+func numbered(src string) string {
+	var sb strings.Builder
+	for i, line := range strings.Split(src, "\n") {
+		fmt.Fprintf(&sb, "%3d  %s\n", i+1, line)
+	}
+	return sb.String()
+}
+
+// stmtKind is a production of the statement grammar
+type stmtKind int
+
+const (
+	sWriteHeader stmtKind = iota // w.WriteHeader(...)
+	sLog                         // log.Println(...)
+	sReturn                      // return
+	sWrite                       // w.Write(...)
+	sNext                        // next.ServeHTTP(w, r)
+	sInc                         // n++, which has no effect
+	sDefer                       // defer log.Println(...)
+	sIf                          // if ... { } else { }
+	sSwitch                      // switch r.Method { case ...: }
+	sSelect                      // select { case <-ch: default: }
+	sLoop                        // for { }
+	sFor                         // for n < 3 { }
+	sGoto                        // goto L
+	sBreak                       // break
+	sContinue                    // continue
+	sBlock                       // { }
+	numKinds
+)
+
+type fuzzStmt struct {
+	kind  stmtKind
+	body  []*fuzzStmt   // sIf, sLoop, sFor, sBlock
+	els   []*fuzzStmt   // sIf, when it has an else branch
+	cases [][]*fuzzStmt // sSwitch and sSelect; the last one is the default
+	dflt  bool          // sSwitch and sSelect have a default case
+	label int           // sGoto, taken modulo the number of labels
+	id    int           // sWriteHeader
+}
+
+// terminal reports whether nothing may follow s in its block
+func (s *fuzzStmt) terminal() bool {
+	switch s.kind {
+	case sReturn, sGoto, sBreak, sContinue:
+		return true
+	}
+	return false
+}
+
+// fuzzProgram is a handler body: top-level statements that may all be the
+// target of a goto
+type fuzzProgram struct {
+	top    []*fuzzStmt
+	writes int
+}
+
+// fuzzGen builds a program from fuzz input, one byte per choice
+type fuzzGen struct {
+	data  []byte
+	prog  *fuzzProgram
+	stmts int
+}
+
+// Bounds on the size of generated programs
+const (
+	maxDepth = 4
+	maxStmts = 60
+)
+
+func generateProgram(data []byte) *fuzzProgram {
+	g := &fuzzGen{data: data, prog: &fuzzProgram{}}
+	g.prog.top = g.block(0, false, false, 8)
+	return g.prog
+}
+
+// choose returns the next choice among n, 0 once the input is exhausted
+func (g *fuzzGen) choose(n int) int {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return int(b) % n
+}
+
+// block generates a statement list. inLoop tells whether continue is
+// allowed, breakable whether break would leave a loop.
+func (g *fuzzGen) block(depth int, inLoop, breakable bool, max int) []*fuzzStmt {
+	n := 1 + g.choose(max)
+	var stmts []*fuzzStmt
+	for i := 0; i < n; i++ {
+		s := g.stmt(depth, inLoop, breakable)
+		stmts = append(stmts, s)
+		if s.terminal() {
+			break
+		}
+	}
+	return stmts
+}
+
+func (g *fuzzGen) stmt(depth int, inLoop, breakable bool) *fuzzStmt {
+	g.stmts++
+	kind := stmtKind(g.choose(int(numKinds)))
+	if kind >= sIf && (depth >= maxDepth || g.stmts >= maxStmts) {
+		// Only simple statements deep down
+		kind = kind % sIf
+	}
+	if (kind == sBreak && !breakable) || (kind == sContinue && !inLoop) {
+		kind = sLog
+	}
+
+	s := &fuzzStmt{kind: kind}
+	switch kind {
+	case sWriteHeader:
+		s.id = g.prog.writes
+		g.prog.writes++
+	case sIf:
+		s.body = g.block(depth+1, inLoop, breakable, 3)
+		if g.choose(2) == 1 {
+			s.els = g.block(depth+1, inLoop, breakable, 3)
+		}
+	case sSwitch, sSelect:
+		// break inside a switch or select leaves that statement
+		n := 1 + g.choose(3)
+		for i := 0; i < n; i++ {
+			s.cases = append(s.cases, g.block(depth+1, inLoop, false, 3))
+		}
+		s.dflt = g.choose(2) == 1
+	case sLoop, sFor:
+		s.body = g.block(depth+1, true, true, 3)
+	case sBlock:
+		s.body = g.block(depth+1, inLoop, breakable, 3)
+	case sGoto:
+		s.label = g.choose(256)
+	}
+	return s
+}
+
+// render returns the source of a middleware running the program, and the
+// WriteHeader id on each line that has one
+func (p *fuzzProgram) render() (string, map[int]int) {
+	r := &renderer{lines: make(map[int]int), labels: make(map[int]bool)}
+	// Labels that are never used do not compile
+	var mark func(stmts []*fuzzStmt)
+	mark = func(stmts []*fuzzStmt) {
+		for _, s := range stmts {
+			if s.kind == sGoto {
+				r.labels[s.label%len(p.top)] = true
+			}
+			mark(s.body)
+			mark(s.els)
+			for _, c := range s.cases {
+				mark(c)
+			}
+		}
+	}
+	mark(p.top)
+
+	r.line("package fuzz")
+	r.line("")
+	r.line(`import (`)
+	r.line(`"log"`)
+	r.line(`"net/http"`)
+	r.line(`)`)
+	r.line("")
+	r.line("func Middleware(next http.Handler) http.Handler {")
+	r.line("return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {")
+	r.line("n := 0")
+	r.line("_ = n")
+	r.line("ch := make(chan int)")
+	r.line("_ = ch")
+	r.line("_ = log.Println")
+	for i, s := range p.top {
+		if r.labels[i] {
+			r.line(fmt.Sprintf("L%d:", i))
+		}
+		r.stmt(s, len(p.top))
+	}
+	r.line("})")
+	r.line("}")
+	return r.sb.String(), r.lines
+}
+
+type renderer struct {
+	sb     strings.Builder
+	n      int
+	lines  map[int]int
+	labels map[int]bool
+}
+
+func (r *renderer) line(s string) {
+	r.sb.WriteString(s)
+	r.sb.WriteString("\n")
+	r.n++
+}
+
+func (r *renderer) block(stmts []*fuzzStmt, labels int) {
+	for _, s := range stmts {
+		r.stmt(s, labels)
+	}
+}
+
+func (r *renderer) stmt(s *fuzzStmt, labels int) {
+	switch s.kind {
+	case sWriteHeader:
+		r.lines[r.n+1] = s.id
+		r.line(fmt.Sprintf("w.WriteHeader(%d)", 400+s.id%100))
+	case sLog:
+		r.line(`log.Println("logged")`)
+	case sReturn:
+		r.line("return")
+	case sWrite:
+		r.line(`w.Write([]byte("body"))`)
+	case sNext:
+		r.line("next.ServeHTTP(w, r)")
+	case sInc:
+		r.line("n++")
+	case sDefer:
+		r.line(`defer log.Println("deferred")`)
+	case sIf:
+		r.line(`if r.URL.Path == "/" {`)
+		r.block(s.body, labels)
+		if s.els != nil {
+			r.line("} else {")
+			r.block(s.els, labels)
+		}
+		r.line("}")
+	case sSwitch, sSelect:
+		if s.kind == sSwitch {
+			r.line("switch r.Method {")
+		} else {
+			r.line("select {")
+		}
+		for i, c := range s.cases {
+			switch {
+			case s.dflt && i == len(s.cases)-1:
+				r.line("default:")
+			case s.kind == sSwitch:
+				r.line(fmt.Sprintf("case \"M%d\":", i))
+			default:
+				r.line("case <-ch:")
+			}
+			r.block(c, labels)
+		}
+		r.line("}")
+	case sLoop:
+		r.line("for {")
+		r.block(s.body, labels)
+		r.line("}")
+	case sFor:
+		r.line("for n < 3 {")
+		r.block(s.body, labels)
+		r.line("}")
+	case sGoto:
+		r.line(fmt.Sprintf("goto L%d", s.label%labels))
+	case sBreak:
+		r.line("break")
+	case sContinue:
+		r.line("continue")
+	case sBlock:
+		r.line("{")
+		r.block(s.body, labels)
+		r.line("}")
+	}
+}
+
+// The reference interpreter runs over a control-flow graph of its own built
+// from the program, independent of go/ssa.
+
+type nodeKind int
+
+const (
+	nEffect nodeKind = iota // any statement with a side effect
+	nWriteHeader
+	nLog
+	nReturn
+	nBranch // a condition or a select
+	nNop    // no effect: n++, labels and loop heads
+	nEnd    // falling off the end of the handler
+)
+
+type node struct {
+	kind  nodeKind
+	succs []int
+	id    int // nWriteHeader
+}
+
+type cfg struct {
+	nodes  []*node
+	labels []int
+}
+
+func (g *cfg) add(kind nodeKind, succs ...int) int {
+	g.nodes = append(g.nodes, &node{kind: kind, succs: succs})
+	return len(g.nodes) - 1
+}
+
+// jumps holds the targets of break and continue in the innermost loop
+type jumps struct {
+	brk, cont int
+}
+
+// build adds stmts to the graph, continuing with next, and returns the
+// entry node
+func (g *cfg) build(stmts []*fuzzStmt, next int, j jumps) int {
+	for i := len(stmts) - 1; i >= 0; i-- {
+		next = g.stmt(stmts[i], next, j)
+	}
+	return next
+}
+
+func (g *cfg) stmt(s *fuzzStmt, next int, j jumps) int {
+	switch s.kind {
+	case sWriteHeader:
+		n := g.add(nWriteHeader, next)
+		g.nodes[n].id = s.id
+		return n
+	case sLog:
+		return g.add(nLog, next)
+	case sReturn:
+		return g.add(nReturn)
+	case sWrite, sNext, sDefer:
+		return g.add(nEffect, next)
+	case sInc:
+		return g.add(nNop, next)
+	case sIf:
+		els := next
+		if s.els != nil {
+			els = g.build(s.els, next, j)
+		}
+		return g.add(nBranch, g.build(s.body, next, j), els)
+	case sSwitch, sSelect:
+		if s.kind == sSwitch && s.dflt && len(s.cases) == 1 {
+			// A switch with only a default case does not branch
+			return g.build(s.cases[0], next, jumps{brk: next, cont: j.cont})
+		}
+		var succs []int
+		for _, c := range s.cases {
+			succs = append(succs, g.build(c, next, jumps{brk: next, cont: j.cont}))
+		}
+		if !s.dflt && s.kind == sSwitch {
+			succs = append(succs, next)
+		}
+		return g.add(nBranch, succs...)
+	case sLoop:
+		head := g.add(nNop)
+		g.nodes[head].succs = []int{g.build(s.body, head, jumps{brk: next, cont: head})}
+		return head
+	case sFor:
+		head := g.add(nBranch)
+		g.nodes[head].succs = []int{g.build(s.body, head, jumps{brk: next, cont: head}), next}
+		return head
+	case sGoto:
+		return g.labels[s.label%len(g.labels)]
+	case sBreak:
+		return j.brk
+	case sContinue:
+		return j.cont
+	case sBlock:
+		return g.build(s.body, next, j)
+	}
+	panic(fmt.Sprintf("unexpected statement kind %d", s.kind))
+}
+
+// violations returns the WriteHeader calls, by id, that the rule should
+// report: those reachable from the start of the handler after which control
+// does not reach a return statement with at most one log call in between,
+// or falls out of the call's own statement list on the way.
+func (p *fuzzProgram) violations() map[int]bool {
+	g := &cfg{}
+	// Every top-level statement may be a goto target, through a nop node
+	// whose successor is filled in below
+	for range p.top {
+		g.labels = append(g.labels, g.add(nNop))
+	}
+	next := g.add(nEnd)
+	for i := len(p.top) - 1; i >= 0; i-- {
+		g.nodes[g.labels[i]].succs = []int{g.stmt(p.top[i], next, jumps{})}
+		next = g.labels[i]
+	}
+	entry := next
+
+	// Unreachable code is not in the SSA form, and so never reported
+	reachable := make(map[int]bool)
+	stack := []int{entry}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reachable[n] {
+			continue
+		}
+		reachable[n] = true
+		stack = append(stack, g.nodes[n].succs...)
+	}
+
+	leaves := make(map[int]bool)
+	fallsOut(p.top, leaves)
+
+	violations := make(map[int]bool)
+	ids := make([]int, 0, len(reachable))
+	for n := range reachable {
+		ids = append(ids, n)
+	}
+	sort.Ints(ids)
+	for _, n := range ids {
+		if g.nodes[n].kind == nWriteHeader && (!g.returnsAfter(n) || leaves[g.nodes[n].id]) {
+			violations[g.nodes[n].id] = true
+		}
+	}
+	return violations
+}
+
+// fallsOut marks the WriteHeader calls in stmts and nested lists that are
+// followed by neither a return nor a goto in their own list
+func fallsOut(stmts []*fuzzStmt, marked map[int]bool) {
+	left := true
+	for i := len(stmts) - 1; i >= 0; i-- {
+		s := stmts[i]
+		switch s.kind {
+		case sWriteHeader:
+			marked[s.id] = left
+		case sReturn, sGoto:
+			left = false
+		}
+		fallsOut(s.body, marked)
+		fallsOut(s.els, marked)
+		for _, c := range s.cases {
+			fallsOut(c, marked)
+		}
+	}
+}
+
+// returnsAfter follows control from the WriteHeader node n and reports
+// whether it reaches a return having run at most one log call
+func (g *cfg) returnsAfter(n int) bool {
+	logs := 0
+	visited := map[int]bool{n: true}
+	for cur := g.nodes[n].succs[0]; ; cur = g.nodes[cur].succs[0] {
+		if visited[cur] {
+			// A loop without a branch never returns
+			return false
+		}
+		visited[cur] = true
+		switch g.nodes[cur].kind {
+		case nReturn:
+			return true
+		case nLog:
+			logs++
+			if logs > 1 {
+				return false
+			}
+		case nNop:
+		default:
+			return false
+		}
+	}
+}
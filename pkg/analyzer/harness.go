@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"runtime"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	analysischecker "golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// The packages imported by sources passed to RunSource are type-checked
+// once and shared by every call, along with their file set
+var (
+	sourceMu       sync.Mutex
+	sourceFset     = token.NewFileSet()
+	sourceImporter = importer.ForCompiler(sourceFset, "source", nil)
+)
+
+// SourceResult is the outcome of running an analyzer with RunSource
+type SourceResult struct {
+	Fset *token.FileSet
+	File *ast.File
+	Pkg  *types.Package
+	// Diagnostics holds the diagnostics of the analyzer in position order
+	Diagnostics []analysis.Diagnostic
+	// Result is the result of the analyzer
+	Result interface{}
+
+	src []byte
+}
+
+// RunSource type-checks src as the only file of a package and runs a over
+// it, along with the analyzers it requires, without go list or a testdata
+// tree. It is meant for table-driven tests of rules:
+//
+//	res, err := analyzer.RunSource(analyzer.Analyzer, src)
+//	for _, diag := range res.Diagnostics {
+//		fmt.Println(res.Position(diag.Pos), diag.Message)
+//	}
+//
+// Imports are type-checked from source and cached across calls, so they
+// must be found in GOROOT or GOPATH. An error is returned if src does not
+// type-check or the analysis fails.
+func RunSource(a *analysis.Analyzer, src string) (*SourceResult, error) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+
+	file, err := parser.ParseFile(sourceFset, "source.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	info := &types.Info{
+		Types:        make(map[ast.Expr]types.TypeAndValue),
+		Defs:         make(map[*ast.Ident]types.Object),
+		Uses:         make(map[*ast.Ident]types.Object),
+		Implicits:    make(map[ast.Node]types.Object),
+		Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		Instances:    make(map[*ast.Ident]types.Instance),
+		FileVersions: make(map[*ast.File]string),
+	}
+	conf := types.Config{Importer: sourceImporter}
+	pkg, err := conf.Check(file.Name.Name, sourceFset, []*ast.File{file}, info)
+	if err != nil {
+		return nil, err
+	}
+
+	graph, err := analysischecker.Analyze([]*analysis.Analyzer{a}, []*packages.Package{{
+		ID:         pkg.Path(),
+		Name:       pkg.Name(),
+		PkgPath:    pkg.Path(),
+		Fset:       sourceFset,
+		Syntax:     []*ast.File{file},
+		Types:      pkg,
+		TypesInfo:  info,
+		TypesSizes: types.SizesFor("gc", runtime.GOARCH),
+	}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	act := graph.Roots[0]
+	if act.Err != nil {
+		return nil, act.Err
+	}
+
+	res := &SourceResult{
+		Fset:        sourceFset,
+		File:        file,
+		Pkg:         pkg,
+		Diagnostics: act.Diagnostics,
+		Result:      act.Result,
+		src:         []byte(src),
+	}
+	sort.SliceStable(res.Diagnostics, func(i, j int) bool {
+		return res.Diagnostics[i].Pos < res.Diagnostics[j].Pos
+	})
+	return res, nil
+}
+
+// Position returns the line and column of pos in the source
+func (r *SourceResult) Position(pos token.Pos) token.Position {
+	return r.Fset.Position(pos)
+}
+
+// Fix applies the first suggested fix of diag to the source and returns the
+// result, formatted with gofmt
+func (r *SourceResult) Fix(diag analysis.Diagnostic) (string, error) {
+	if len(diag.SuggestedFixes) == 0 {
+		return "", fmt.Errorf("%s: no suggested fix", diag.Message)
+	}
+	file := r.Fset.File(r.File.Pos())
+
+	edits := append([]analysis.TextEdit(nil), diag.SuggestedFixes[0].TextEdits...)
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Pos > edits[j].Pos
+	})
+	out := append([]byte(nil), r.src...)
+	for _, edit := range edits {
+		end := edit.End
+		if !end.IsValid() {
+			end = edit.Pos
+		}
+		start, stop := file.Offset(edit.Pos), file.Offset(end)
+		out = append(out[:start:start], append(append([]byte(nil), edit.NewText...), out[stop:]...)...)
+	}
+
+	formatted, err := format.Source(out)
+	if err != nil {
+		return "", fmt.Errorf("fixed source does not parse: %v", err)
+	}
+	return string(formatted), nil
+}
@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// syntaxIndex records what the analyzers need from the syntax of a package.
+// It is built in a single traversal and shared by handler discovery, route
+// discovery and the checks, so no function body is walked more than once,
+// however deeply handler literals are nested.
+type syntaxIndex struct {
+	// calls holds every call expression by the position of its opening
+	// parenthesis, which is how SSA call instructions record their source
+	// position
+	calls map[token.Pos]*ast.CallExpr
+	// dropped holds the calls used as expression statements, whose results
+	// are discarded
+	dropped map[*ast.CallExpr]bool
+	// middleware maps each function literal passed to http.HandlerFunc inside
+	// a middleware constructor to that constructor
+	middleware map[*ast.FuncLit]*ast.FuncDecl
+	// statusCalls lists the WriteHeader calls in source order
+	statusCalls []*ast.CallExpr
+	// blockEnds maps the WriteHeader calls whose statement is followed by
+	// neither a return nor a goto or fallthrough in its own statement list
+	// to the end of that list: control falls out of the block after them
+	blockEnds map[*ast.CallExpr]token.Pos
+	// routeNodes lists the assignments and calls that may set up routes, in
+	// source order
+	routeNodes []ast.Node
+}
+
+func newSyntaxIndex(inspect *inspector.Inspector) *syntaxIndex {
+	index := &syntaxIndex{
+		calls:      make(map[token.Pos]*ast.CallExpr),
+		dropped:    make(map[*ast.CallExpr]bool),
+		middleware: make(map[*ast.FuncLit]*ast.FuncDecl),
+		blockEnds:  make(map[*ast.CallExpr]token.Pos),
+	}
+
+	for cur := range inspect.Root().Preorder((*ast.AssignStmt)(nil), (*ast.CallExpr)(nil)) {
+		switch n := cur.Node().(type) {
+		case *ast.AssignStmt:
+			index.routeNodes = append(index.routeNodes, n)
+		case *ast.CallExpr:
+			index.calls[n.Lparen] = n
+			if _, ok := cur.Parent().Node().(*ast.ExprStmt); ok {
+				index.dropped[n] = true
+			}
+			index.routeNodes = append(index.routeNodes, n)
+			if IsWriteHeaderCall(n) {
+				index.statusCalls = append(index.statusCalls, n)
+				if end, ok := leavesBlock(cur.Parent()); ok {
+					index.blockEnds[n] = end
+				}
+			}
+
+			// Look for the pattern: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { ... })
+			// inside func <name>(handler http.Handler) http.Handler
+			if !isHandlerFuncCall(n) || len(n.Args) == 0 {
+				continue
+			}
+			lit, ok := n.Args[0].(*ast.FuncLit)
+			if !ok {
+				continue
+			}
+			for decl := range cur.Enclosing((*ast.FuncDecl)(nil)) {
+				if decl := decl.Node().(*ast.FuncDecl); isMiddlewarePattern(decl) {
+					index.middleware[lit] = decl
+				}
+			}
+		}
+	}
+	return index
+}
+
+// leavesBlock reports whether stmt, a statement of a block, case or comm
+// clause, is followed by no return, goto or fallthrough statement in that
+// statement list, and returns the end of the list
+func leavesBlock(stmt inspector.Cursor) (token.Pos, bool) {
+	parent := stmt.Parent()
+	for {
+		if _, ok := parent.Node().(*ast.LabeledStmt); !ok {
+			break
+		}
+		stmt, parent = parent, parent.Parent()
+	}
+
+	var (
+		list []ast.Stmt
+		end  token.Pos
+	)
+	switch n := parent.Node().(type) {
+	case *ast.BlockStmt:
+		list, end = n.List, n.Rbrace
+	case *ast.CaseClause:
+		list, end = n.Body, n.End()
+	case *ast.CommClause:
+		list, end = n.Body, n.End()
+	default:
+		return token.NoPos, false
+	}
+	for i, s := range list {
+		if s != stmt.Node() {
+			continue
+		}
+		for _, next := range list[i+1:] {
+			for {
+				labeled, ok := next.(*ast.LabeledStmt)
+				if !ok {
+					break
+				}
+				next = labeled.Stmt
+			}
+			switch next := next.(type) {
+			case *ast.ReturnStmt:
+				return token.NoPos, false
+			case *ast.BranchStmt:
+				if next.Tok == token.GOTO || next.Tok == token.FALLTHROUGH {
+					return token.NoPos, false
+				}
+			}
+		}
+		return end, true
+	}
+	return token.NoPos, false
+}
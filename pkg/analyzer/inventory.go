@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/ssa"
+)
+
+// HandlerInventory finds the HTTP handler function bodies of a package. It
+// reports nothing; analyzers that check handlers require it and read its
+// *Inventory result.
+var HandlerInventory = &analysis.Analyzer{
+	Name:       "handlerinventory",
+	Doc:        "discovers HTTP handler functions, ServeHTTP methods and middleware in a package",
+	URL:        docsURL,
+	Run:        runInventory,
+	Requires:   []*analysis.Analyzer{inspect.Analyzer, httpSSA},
+	ResultType: reflect.TypeOf((*Inventory)(nil)),
+}
+
+// Inventory is the result of the HandlerInventory analyzer
+type Inventory struct {
+	// Handlers lists every handler of the package in source order
+	Handlers []*Handler
+	// Routes lists the route registrations of the package in source order
+	Routes []*Route
+
+	index *syntaxIndex
+}
+
+// Handler is a function body that serves an HTTP request: any function with
+// the signature func(http.ResponseWriter, *http.Request), including
+// ServeHTTP methods and function literals.
+type Handler struct {
+	// Name is the name of the function relative to its package, e.g.
+	// "Auth$1" for the first function literal inside Auth
+	Name string
+	Kind HandlerKind
+	// Func is the SSA form of the handler
+	Func *ssa.Function
+	// Syntax is the *ast.FuncDecl or *ast.FuncLit of the handler
+	Syntax ast.Node
+	// Writer and Request are the http.ResponseWriter and *http.Request
+	// parameters
+	Writer, Request *types.Var
+	// Middleware is the func(http.Handler) http.Handler declaration that
+	// returns a MiddlewareHandler, and nil for other kinds
+	Middleware *ast.FuncDecl
+	// Next is the handler being wrapped, if any: the http.Handler parameter
+	// of the middleware declaration, or an http.Handler field of the
+	// receiver of a ServeHTTP method
+	Next *types.Var
+	// Routes are the registrations in the package that serve requests with
+	// the handler
+	Routes []*Route
+}
+
+// Pos returns the start of the handler's declaration or literal
+func (h *Handler) Pos() token.Pos { return h.Syntax.Pos() }
+
+// End returns the end of the handler's declaration or literal
+func (h *Handler) End() token.Pos { return h.Syntax.End() }
+
+// HandlerKind classifies how a handler function was discovered
+type HandlerKind int
+
+const (
+	// MiddlewareHandler is a http.HandlerFunc literal returned by a
+	// func(http.Handler) http.Handler
+	MiddlewareHandler HandlerKind = iota
+	// FuncHandler is any other function with the signature
+	// func(http.ResponseWriter, *http.Request)
+	FuncHandler
+	// ServeHTTPHandler is a ServeHTTP method
+	ServeHTTPHandler
+)
+
+// String returns "middleware", "handler" or "ServeHTTP"
+func (k HandlerKind) String() string {
+	switch k {
+	case MiddlewareHandler:
+		return "middleware"
+	case FuncHandler:
+		return "handler"
+	case ServeHTTPHandler:
+		return "ServeHTTP"
+	}
+	return fmt.Sprintf("HandlerKind(%d)", int(k))
+}
+
+func runInventory(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ssaInfo := pass.ResultOf[httpSSA].(*buildssa.SSA)
+	if ssaInfo.Pkg == nil {
+		// The package does not use net/http
+		return &Inventory{}, nil
+	}
+
+	index := newSyntaxIndex(inspect)
+	middleware := index.middleware
+
+	inventory := &Inventory{index: index}
+	for _, fn := range ssaInfo.SrcFuncs {
+		if !isHandlerSignature(fn.Signature) {
+			continue
+		}
+
+		params := fn.Signature.Params()
+		h := &Handler{
+			Name:    fn.RelString(fn.Pkg.Pkg),
+			Kind:    FuncHandler,
+			Func:    fn,
+			Syntax:  fn.Syntax(),
+			Writer:  params.At(0),
+			Request: params.At(1),
+		}
+		if lit, ok := fn.Syntax().(*ast.FuncLit); ok && middleware[lit] != nil {
+			h.Kind = MiddlewareHandler
+			h.Middleware = middleware[lit]
+			h.Next = wrappedParam(pass.TypesInfo, h.Middleware)
+		} else if recv := fn.Signature.Recv(); recv != nil && fn.Name() == "ServeHTTP" {
+			h.Kind = ServeHTTPHandler
+			h.Next = wrappedField(recv.Type())
+		}
+		inventory.Handlers = append(inventory.Handlers, h)
+	}
+
+	findRoutes(pass, inventory)
+	return inventory, nil
+}
+
+// isHandlerSignature checks if the signature is
+// func(http.ResponseWriter, *http.Request), ignoring any receiver
+func isHandlerSignature(sig *types.Signature) bool {
+	params := sig.Params()
+	if params.Len() != 2 || sig.Results().Len() != 0 {
+		return false
+	}
+	if !isResponseWriterType(params.At(0).Type()) {
+		return false
+	}
+	ptr, ok := params.At(1).Type().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := types.Unalias(ptr.Elem()).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "net/http" && obj.Name() == "Request"
+}
+
+// wrappedParam returns the first http.Handler parameter of a middleware
+// declaration
+func wrappedParam(info *types.Info, decl *ast.FuncDecl) *types.Var {
+	for _, field := range decl.Type.Params.List {
+		for _, name := range field.Names {
+			if v, ok := info.Defs[name].(*types.Var); ok && isHTTPHandlerType(v.Type()) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// wrappedField returns the first http.Handler field of the struct a
+// ServeHTTP method is declared on
+func wrappedField(recv types.Type) *types.Var {
+	if ptr, ok := types.Unalias(recv).(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	st, ok := recv.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if field := st.Field(i); isHTTPHandlerType(field.Type()) {
+			return field
+		}
+	}
+	return nil
+}
+
+// isHTTPHandlerType reports whether t is the named type net/http.Handler
+func isHTTPHandlerType(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "net/http" && obj.Name() == "Handler"
+}
@@ -0,0 +1,250 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/constant"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// StatusPolicy restricts the constant status codes written by the handlers
+// in its scope. A handler is in scope when it matches every scope field that
+// is set, and a field matches when any of its patterns does.
+type StatusPolicy struct {
+	// Name identifies the policy in the messages of its findings
+	Name string `json:"name"`
+	// Message, if set, explains the policy after its name in findings
+	Message string `json:"message,omitempty"`
+
+	// Packages are import paths; one ending in "/..." also matches the
+	// packages below it, e.g. "example.com/api/...".
+	Packages []string `json:"packages,omitempty"`
+	// Functions are path.Match patterns for the function declaring the
+	// handler, named relative to its package: "CreateUser", "Validate*" or
+	// "(*Server).ServeHTTP". A function literal counts as part of the
+	// function it appears in.
+	Functions []string `json:"functions,omitempty"`
+	// Routes are path.Match patterns for a route the handler serves,
+	// matched against the route with and without its method, e.g.
+	// "GET /admin/*" or "/admin/*".
+	Routes []string `json:"routes,omitempty"`
+	// Kinds are handler kinds as printed by HandlerKind.String:
+	// "middleware", "handler" or "ServeHTTP".
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Allow, if set, lists the only statuses the handlers may write, and
+	// Deny lists statuses they must not write. A status is a code such as
+	// "404", a class such as "4xx" or a range such as "400-417".
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// statusPolicy is the parsed form of a StatusPolicy
+type statusPolicy struct {
+	*StatusPolicy
+	allow, deny []statusRange
+}
+
+// statusRange is an inclusive range of status codes
+type statusRange struct {
+	lo, hi int64
+}
+
+func newStatusPolicy(p StatusPolicy) (*statusPolicy, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("status policy without a name")
+	}
+	for _, patterns := range [][]string{p.Functions, p.Routes} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("status policy %q: bad pattern %q", p.Name, pattern)
+			}
+		}
+	}
+	for _, kind := range p.Kinds {
+		switch kind {
+		case MiddlewareHandler.String(), FuncHandler.String(), ServeHTTPHandler.String():
+		default:
+			return nil, fmt.Errorf("status policy %q: unknown handler kind %q, want middleware, handler or ServeHTTP", p.Name, kind)
+		}
+	}
+	if len(p.Allow) == 0 && len(p.Deny) == 0 {
+		return nil, fmt.Errorf("status policy %q allows every status; set allow or deny", p.Name)
+	}
+
+	policy := &statusPolicy{StatusPolicy: &p}
+	for _, list := range []struct {
+		specs  []string
+		ranges *[]statusRange
+	}{{p.Allow, &policy.allow}, {p.Deny, &policy.deny}} {
+		for _, spec := range list.specs {
+			r, err := parseStatusRange(spec)
+			if err != nil {
+				return nil, fmt.Errorf("status policy %q: %v", p.Name, err)
+			}
+			*list.ranges = append(*list.ranges, r)
+		}
+	}
+	return policy, nil
+}
+
+// parseStatusRange parses a status code, class or range of a StatusPolicy
+func parseStatusRange(spec string) (statusRange, error) {
+	bad := fmt.Errorf("bad status %q: want a code such as 404, a class such as 4xx or a range such as 400-417", spec)
+	if len(spec) == 3 && strings.HasSuffix(spec, "xx") {
+		class, err := strconv.ParseInt(spec[:1], 10, 64)
+		if err != nil || class < 1 {
+			return statusRange{}, bad
+		}
+		return statusRange{class * 100, class*100 + 99}, nil
+	}
+	lo, hi, isRange := strings.Cut(spec, "-")
+	if !isRange {
+		hi = lo
+	}
+	var r statusRange
+	var errLo, errHi error
+	r.lo, errLo = strconv.ParseInt(lo, 10, 64)
+	r.hi, errHi = strconv.ParseInt(hi, 10, 64)
+	if errLo != nil || errHi != nil || r.lo < 100 || r.hi > 999 || r.lo > r.hi {
+		return statusRange{}, bad
+	}
+	return r, nil
+}
+
+// permits reports whether the policy lets handlers write code
+func (p *statusPolicy) permits(code int64) bool {
+	in := func(ranges []statusRange) bool {
+		for _, r := range ranges {
+			if r.lo <= code && code <= r.hi {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(p.allow) == 0 || in(p.allow)) && !in(p.deny)
+}
+
+// applies reports whether h, in the package with import path pkgPath, is
+// in the scope of the policy
+func (p *statusPolicy) applies(pkgPath string, h *Handler) bool {
+	if len(p.Packages) > 0 && !anyMatch(p.Packages, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+			return pkgPath == prefix || strings.HasPrefix(pkgPath, prefix+"/")
+		}
+		return pkgPath == pattern
+	}) {
+		return false
+	}
+	// Function literals are named after their enclosing function, as in
+	// "Auth$1"
+	decl, _, _ := strings.Cut(h.Name, "$")
+	if len(p.Functions) > 0 && !anyMatch(p.Functions, func(pattern string) bool {
+		ok, _ := path.Match(pattern, decl)
+		return ok
+	}) {
+		return false
+	}
+	if len(p.Routes) > 0 && !anyMatch(p.Routes, func(pattern string) bool {
+		for _, route := range h.Routes {
+			if ok, _ := path.Match(pattern, route.String()); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, route.Pattern); ok {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+	if len(p.Kinds) > 0 && !anyMatch(p.Kinds, func(kind string) bool {
+		return kind == h.Kind.String()
+	}) {
+		return false
+	}
+	return true
+}
+
+// anyMatch reports whether match holds for any of patterns
+func anyMatch(patterns []string, match func(string) bool) bool {
+	for _, pattern := range patterns {
+		if match(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStatusPolicies reports the constant statuses written through the
+// tracked writer of h that a policy in whose scope h is does not permit.
+func checkStatusPolicies(c *checker, h *handler) {
+	var policies []*statusPolicy
+	for _, p := range c.config.policies {
+		if p.applies(c.pass.Pkg.Path(), h.Handler) {
+			policies = append(policies, p)
+		}
+	}
+	if len(policies) == 0 {
+		return
+	}
+
+	forEachInstr(h.Func, func(instr ssa.Instruction) {
+		call, ok := instr.(*ssa.Call)
+		if !ok {
+			return
+		}
+		code, ok := h.aliases.writtenStatus(call.Common())
+		if !ok {
+			return
+		}
+		callExpr := c.index.calls[call.Pos()]
+		if callExpr == nil {
+			return
+		}
+		for _, p := range policies {
+			if p.permits(code) {
+				continue
+			}
+			if p.Message != "" {
+				c.reportf(ruleStatusPolicy, callExpr.Pos(), "status %d violates policy %q: %s", code, p.Name, p.Message)
+			} else {
+				c.reportf(ruleStatusPolicy, callExpr.Pos(), "status %d violates policy %q", code, p.Name)
+			}
+		}
+	})
+}
+
+// writtenStatus returns the status written by call if it is constant and
+// call writes it through the tracked writer: a WriteHeader call on the
+// writer, or http.Error or http.Redirect with the writer.
+func (a *writerAliases) writtenStatus(call *ssa.CallCommon) (int64, bool) {
+	if recv := receiver(call); recv != nil {
+		if !a.values[recv] || !isStatusMethodCall(call) {
+			return 0, false
+		}
+		return statusArg(call)
+	}
+
+	callee := call.StaticCallee()
+	if callee == nil || callee.Pkg == nil || callee.Pkg.Pkg.Path() != "net/http" {
+		return 0, false
+	}
+	switch callee.Name() {
+	case "Error", "Redirect":
+	default:
+		return 0, false
+	}
+	if len(call.Args) == 0 || !a.refersTo(call.Args[0]) {
+		return 0, false
+	}
+	// The status is the last argument of both
+	k, ok := call.Args[len(call.Args)-1].(*ssa.Const)
+	if !ok || k.Value == nil || k.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(k.Value)
+}
@@ -0,0 +1,474 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Route is a handler registration on a router: a call such as
+// http.HandleFunc, (*http.ServeMux).Handle, a chi router method or a
+// gorilla/mux HandleFunc.
+type Route struct {
+	// Method is the HTTP method the route is restricted to, or "" for any
+	Method string
+	// Pattern is the path pattern, including any host and any prefix of
+	// an enclosing chi route group or gorilla subrouter
+	Pattern string
+	// Pos is the position of the registration call
+	Pos token.Pos
+	// Handlers are the handlers of the package serving the route,
+	// including its middleware. It is empty when the registered handler is
+	// declared in another package.
+	Handlers []*Handler
+	// Wrappers are the middleware functions the route runs through,
+	// outermost first: those applied to the router with Use or With, then
+	// those wrapping the registered handler, as in Auth(Logging(h)). They
+	// may be declared in other packages.
+	Wrappers []*types.Func
+	// Middleware are the handlers of the Wrappers declared in the package
+	Middleware []*Handler
+}
+
+// String returns the route as written in a Go 1.22 ServeMux pattern, e.g.
+// "GET /admin/users"
+func (r *Route) String() string {
+	if r.Method == "" {
+		return r.Pattern
+	}
+	return r.Method + " " + r.Pattern
+}
+
+// Import paths of the supported third-party routers. chi is matched by
+// prefix to cover its major versions.
+const (
+	chiPath     = "github.com/go-chi/chi"
+	gorillaPath = "github.com/gorilla/mux"
+)
+
+// chiMethods maps the chi router methods registering a handler for a single
+// HTTP method to that method
+var chiMethods = map[string]string{
+	"Connect": "CONNECT",
+	"Delete":  "DELETE",
+	"Get":     "GET",
+	"Head":    "HEAD",
+	"Options": "OPTIONS",
+	"Patch":   "PATCH",
+	"Post":    "POST",
+	"Put":     "PUT",
+	"Trace":   "TRACE",
+}
+
+// routeFinder discovers the routes of a package
+type routeFinder struct {
+	info *types.Info
+	// prefixes holds the path prefix of chi route group and gorilla
+	// subrouter variables
+	prefixes map[types.Object]string
+	// uses holds the middleware applied with Use to chi and gorilla router
+	// variables, outermost first
+	uses map[types.Object][]*types.Func
+	// methods holds the methods of gorilla routes restricted with
+	// .Methods(...), keyed by the registration call
+	methods map[*ast.CallExpr][]string
+	// handlers are the handlers of the package, indexed by byObject and
+	// byLit
+	handlers []*Handler
+	byObject map[types.Object]*Handler
+	byLit    map[*ast.FuncLit]*Handler
+}
+
+// findRoutes records the routes registered in the package on inventory and
+// on the handlers serving them.
+func findRoutes(pass *analysis.Pass, inventory *Inventory) {
+	f := &routeFinder{
+		info:     pass.TypesInfo,
+		prefixes: make(map[types.Object]string),
+		uses:     make(map[types.Object][]*types.Func),
+		methods:  make(map[*ast.CallExpr][]string),
+		handlers: inventory.Handlers,
+		byObject: make(map[types.Object]*Handler),
+		byLit:    make(map[*ast.FuncLit]*Handler),
+	}
+	for _, h := range inventory.Handlers {
+		if lit, ok := h.Syntax.(*ast.FuncLit); ok {
+			f.byLit[lit] = h
+		} else if obj := h.Func.Object(); obj != nil {
+			f.byObject[obj] = h
+		}
+	}
+
+	for _, n := range inventory.index.routeNodes {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			f.subrouterAssign(n)
+		case *ast.CallExpr:
+			f.gorillaMethods(n)
+			f.chiGroup(n)
+			f.useCall(n)
+			for _, route := range f.registration(n) {
+				inventory.Routes = append(inventory.Routes, route)
+				for _, h := range route.Handlers {
+					h.Routes = append(h.Routes, route)
+				}
+			}
+		}
+	}
+}
+
+// registration returns the routes registered by call, if it is a route
+// registration with a constant pattern
+func (f *routeFinder) registration(call *ast.CallExpr) []*Route {
+	fn, ok := typeutil.Callee(f.info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return nil
+	}
+	name := fn.Name()
+
+	var method string
+	var patternArg, handlerArg ast.Expr
+	switch path := fn.Pkg().Path(); {
+	case path == "net/http" && (name == "Handle" || name == "HandleFunc") && len(call.Args) == 2:
+		patternArg, handlerArg = call.Args[0], call.Args[1]
+	case strings.HasPrefix(path, chiPath) && (name == "Handle" || name == "HandleFunc") && len(call.Args) == 2:
+		patternArg, handlerArg = call.Args[0], call.Args[1]
+	case strings.HasPrefix(path, chiPath) && chiMethods[name] != "" && len(call.Args) == 2:
+		method = chiMethods[name]
+		patternArg, handlerArg = call.Args[0], call.Args[1]
+	case strings.HasPrefix(path, chiPath) && (name == "Method" || name == "MethodFunc") && len(call.Args) == 3:
+		var ok bool
+		if method, ok = f.constString(call.Args[0]); !ok {
+			return nil
+		}
+		method = strings.ToUpper(method)
+		patternArg, handlerArg = call.Args[1], call.Args[2]
+	case path == gorillaPath && (name == "Handle" || name == "HandleFunc") && len(call.Args) == 2:
+		patternArg, handlerArg = call.Args[0], call.Args[1]
+	default:
+		return nil
+	}
+
+	pattern, ok := f.constString(patternArg)
+	if !ok {
+		return nil
+	}
+	if method == "" {
+		// Go 1.22 ServeMux patterns: [METHOD ][HOST]/[PATH]
+		if m, rest, ok := strings.Cut(pattern, " "); ok {
+			method, pattern = m, strings.TrimLeft(rest, " \t")
+		}
+	}
+	pattern = joinPath(f.prefix(call), pattern)
+
+	// Middleware applied to the router runs before the registered handler
+	wrappers, endpoints := f.resolve(handlerArg)
+	wrappers = append(f.routerChain(receiverOf(call)), wrappers...)
+	var middleware []*Handler
+	for _, fn := range wrappers {
+		middleware = append(middleware, f.handlersOf(fn)...)
+	}
+	handlers := append(middleware[:len(middleware):len(middleware)], endpoints...)
+
+	methods := []string{method}
+	if ms := f.methods[call]; len(ms) > 0 {
+		methods = ms
+	}
+	var routes []*Route
+	for _, m := range methods {
+		routes = append(routes, &Route{
+			Method:     m,
+			Pattern:    pattern,
+			Pos:        call.Pos(),
+			Handlers:   handlers,
+			Wrappers:   wrappers,
+			Middleware: middleware,
+		})
+	}
+	return routes
+}
+
+// gorillaMethods records the methods of a gorilla route restricted with
+// r.HandleFunc(...).Methods(...). The outer call is visited before the
+// registration it restricts.
+func (f *routeFinder) gorillaMethods(call *ast.CallExpr) {
+	if !f.isGorillaMethod(call, "Methods") {
+		return
+	}
+	inner, ok := ast.Unparen(ast.Unparen(call.Fun).(*ast.SelectorExpr).X).(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	for _, arg := range call.Args {
+		if m, ok := f.constString(arg); ok {
+			f.methods[inner] = append(f.methods[inner], strings.ToUpper(m))
+		}
+	}
+}
+
+// chiGroup records the prefix of the router parameter of a chi route group
+// declared with r.Route(pattern, func(r chi.Router) { ... }) or r.Group.
+func (f *routeFinder) chiGroup(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(f.info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || !strings.HasPrefix(fn.Pkg().Path(), chiPath) {
+		return
+	}
+
+	prefix := f.prefix(call)
+	switch {
+	case fn.Name() == "Route" && len(call.Args) == 2:
+		pattern, ok := f.constString(call.Args[0])
+		if !ok {
+			return
+		}
+		prefix = joinPath(prefix, pattern)
+	case fn.Name() == "Group" && len(call.Args) == 1:
+	default:
+		return
+	}
+
+	lit, ok := ast.Unparen(call.Args[len(call.Args)-1]).(*ast.FuncLit)
+	if !ok || len(lit.Type.Params.List) != 1 || len(lit.Type.Params.List[0].Names) != 1 {
+		return
+	}
+	if obj := f.info.Defs[lit.Type.Params.List[0].Names[0]]; obj != nil {
+		f.prefixes[obj] = prefix
+		f.uses[obj] = f.routerChain(receiverOf(call))
+	}
+}
+
+// useCall records the middleware applied to a chi or gorilla router with
+// r.Use(mw...)
+func (f *routeFinder) useCall(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(f.info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Name() != "Use" {
+		return
+	}
+	if path := fn.Pkg().Path(); !strings.HasPrefix(path, chiPath) && path != gorillaPath {
+		return
+	}
+	ident, ok := ast.Unparen(receiverOf(call)).(*ast.Ident)
+	if !ok {
+		return
+	}
+	obj := f.info.Uses[ident]
+	if obj == nil {
+		return
+	}
+	chain := f.uses[obj]
+	for _, arg := range call.Args {
+		if fn := f.middlewareFunc(arg); fn != nil {
+			chain = append(chain[:len(chain):len(chain)], fn)
+		}
+	}
+	f.uses[obj] = chain
+}
+
+// routerChain returns the middleware applied to the router expression recv:
+// a router variable, or a chi r.With(mw...) call on one
+func (f *routeFinder) routerChain(recv ast.Expr) []*types.Func {
+	switch e := ast.Unparen(recv).(type) {
+	case *ast.Ident:
+		return f.uses[f.info.Uses[e]]
+	case *ast.CallExpr:
+		fn, ok := typeutil.Callee(f.info, e).(*types.Func)
+		if !ok || fn.Pkg() == nil || !strings.HasPrefix(fn.Pkg().Path(), chiPath) || fn.Name() != "With" {
+			return nil
+		}
+		chain := f.routerChain(receiverOf(e))
+		for _, arg := range e.Args {
+			if fn := f.middlewareFunc(arg); fn != nil {
+				chain = append(chain[:len(chain):len(chain)], fn)
+			}
+		}
+		return chain
+	}
+	return nil
+}
+
+// subrouterAssign records the prefix of a gorilla subrouter declared with
+// sub := r.PathPrefix(prefix).Subrouter()
+func (f *routeFinder) subrouterAssign(assign *ast.AssignStmt) {
+	if len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return
+	}
+	ident, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return
+	}
+	sub, ok := ast.Unparen(assign.Rhs[0]).(*ast.CallExpr)
+	if !ok || !f.isGorillaMethod(sub, "Subrouter") {
+		return
+	}
+	pathPrefix, ok := ast.Unparen(ast.Unparen(sub.Fun).(*ast.SelectorExpr).X).(*ast.CallExpr)
+	if !ok || !f.isGorillaMethod(pathPrefix, "PathPrefix") || len(pathPrefix.Args) != 1 {
+		return
+	}
+	pattern, ok := f.constString(pathPrefix.Args[0])
+	if !ok {
+		return
+	}
+	obj := f.info.Defs[ident]
+	if obj == nil {
+		obj = f.info.Uses[ident]
+	}
+	if obj != nil {
+		f.prefixes[obj] = joinPath(f.prefix(pathPrefix), pattern)
+		f.uses[obj] = f.routerChain(receiverOf(pathPrefix))
+	}
+}
+
+// isGorillaMethod reports whether call is a call of the gorilla/mux method name
+func (f *routeFinder) isGorillaMethod(call *ast.CallExpr, name string) bool {
+	fn, ok := typeutil.Callee(f.info, call).(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == gorillaPath && fn.Name() == name
+}
+
+// prefix returns the path prefix of the router a method is called on
+func (f *routeFinder) prefix(call *ast.CallExpr) string {
+	recv := ast.Unparen(receiverOf(call))
+	for {
+		// r.With(mw).Get(...) registers on r
+		inner, ok := recv.(*ast.CallExpr)
+		if !ok {
+			break
+		}
+		recv = ast.Unparen(receiverOf(inner))
+	}
+	ident, ok := recv.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	return f.prefixes[f.info.Uses[ident]]
+}
+
+// receiverOf returns the receiver expression of a method call, or nil
+func receiverOf(call *ast.CallExpr) ast.Expr {
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+		return sel.X
+	}
+	return nil
+}
+
+// resolve returns the middleware functions wrapping expr, the handler
+// argument of a registration, outermost first, and the handlers of the
+// package it otherwise refers to.
+func (f *routeFinder) resolve(expr ast.Expr) ([]*types.Func, []*Handler) {
+	expr = ast.Unparen(expr)
+	switch e := expr.(type) {
+	case *ast.FuncLit:
+		if h := f.byLit[e]; h != nil {
+			return nil, []*Handler{h}
+		}
+		return nil, nil
+	case *ast.CallExpr:
+		if tv, ok := f.info.Types[e.Fun]; ok && tv.IsType() && len(e.Args) == 1 {
+			// A conversion such as http.HandlerFunc(fn)
+			return f.resolve(e.Args[0])
+		}
+		var wrappers []*types.Func
+		var handlers []*Handler
+		if fn := f.middlewareFunc(e.Fun); fn != nil {
+			wrappers = append(wrappers, fn)
+		}
+		for _, arg := range e.Args {
+			w, h := f.resolve(arg)
+			wrappers = append(wrappers, w...)
+			handlers = append(handlers, h...)
+		}
+		return wrappers, handlers
+	case *ast.Ident, *ast.SelectorExpr:
+		if fn, ok := f.funcOf(e).(*types.Func); ok {
+			if h := f.byObject[fn]; h != nil {
+				return nil, []*Handler{h}
+			}
+			return nil, nil
+		}
+	}
+
+	// Any other value serves the request through its ServeHTTP method
+	t := f.info.TypeOf(expr)
+	if t == nil {
+		return nil, nil
+	}
+	if obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "ServeHTTP"); obj != nil {
+		if h := f.byObject[obj]; h != nil {
+			return nil, []*Handler{h}
+		}
+	}
+	return nil, nil
+}
+
+// middlewareFunc returns the function expr refers to if it has a middleware
+// signature, e.g. Auth or httpx.Auth
+func (f *routeFinder) middlewareFunc(expr ast.Expr) *types.Func {
+	if fn, ok := f.funcOf(expr).(*types.Func); ok && isMiddlewareFunc(fn) {
+		return fn
+	}
+	return nil
+}
+
+// handlersOf returns the handlers of the package returned by the middleware
+// function fn
+func (f *routeFinder) handlersOf(fn *types.Func) []*Handler {
+	var handlers []*Handler
+	for _, h := range f.handlers {
+		if h.Middleware != nil && f.info.Defs[h.Middleware.Name] == fn {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+// funcOf returns the object referred to by an identifier, qualified
+// identifier or method value, or nil
+func (f *routeFinder) funcOf(expr ast.Expr) types.Object {
+	expr = ast.Unparen(expr)
+	if sel, ok := expr.(*ast.SelectorExpr); ok {
+		if selection, ok := f.info.Selections[sel]; ok {
+			return selection.Obj()
+		}
+		return f.info.Uses[sel.Sel]
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return f.info.Uses[id]
+	}
+	return nil
+}
+
+// constString returns the value of expr if it is a constant string
+func (f *routeFinder) constString(expr ast.Expr) (string, bool) {
+	tv, ok := f.info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// joinPath appends pattern to the prefix of a route group or subrouter
+func joinPath(prefix, pattern string) string {
+	if prefix == "" {
+		return pattern
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(pattern, "/")
+}
+
+// isMiddlewareFunc reports whether fn has a middleware signature: it
+// returns an http.Handler and takes one as a parameter
+func isMiddlewareFunc(fn *types.Func) bool {
+	sig := fn.Signature()
+	if sig.Results().Len() != 1 || !isHTTPHandlerType(sig.Results().At(0).Type()) {
+		return false
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		if isHTTPHandlerType(sig.Params().At(i).Type()) {
+			return true
+		}
+	}
+	return false
+}
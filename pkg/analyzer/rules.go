@@ -0,0 +1,415 @@
+package analyzer
+
+import (
+	"golang.org/x/tools/go/analysis"
+)
+
+//go:generate go run ../../docs/rules -dir ../../docs/rules
+
+// docsURL is where the generated rule documentation is published
+const docsURL = "https://github.com/3-2-1-contact/return-linter/blob/main/docs/rules/"
+
+// Rule describes one check of the linter. Its ID is stable and is used as
+// the category of every diagnostic the rule reports, so findings can be
+// grouped and suppressed by rule.
+type Rule struct {
+	// ID is the stable identifier of the rule, e.g. RL001
+	ID string
+	// Name is the name of the rule's analyzer and command-line flag
+	Name string
+	// Doc is a one-line summary of what the rule checks
+	Doc string
+	// Details explains why the rule exists, in Markdown
+	Details string
+	// Bad and Good are example handlers that do and do not trigger the rule
+	Bad, Good string
+	// Optional rules enforce a convention rather than catch a bug. Drivers
+	// only run them when they are enabled explicitly.
+	Optional bool
+}
+
+// URL returns the location of the rule's documentation
+func (r *Rule) URL() string {
+	return docsURL + r.ID + ".md"
+}
+
+var ruleReturnAfterStatus = &Rule{
+	ID:   "RL001",
+	Name: "returnafterstatus",
+	Doc:  "checks that w.WriteHeader() calls are followed by return statements in http.Handler middleware",
+	Details: `Middleware that writes a status usually means to reject the request. Any code
+that runs afterwards may write more of the response or hand the request on to
+the next handler, so control must reach an explicit ` + "`return`" + ` right after
+` + "`WriteHeader()`" + `. A single ` + "`log`" + ` call in between is allowed, and informational
+1xx statuses are exempt.`,
+	Bad: `func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthorized"))
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+	Good: `func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+}
+
+var ruleStatusCode = &Rule{
+	ID:   "RL002",
+	Name: "statuscode",
+	Doc:  "checks that status codes passed to WriteHeader are valid and use the http.Status* constants",
+	Details: `` + "`net/http`" + ` panics when a handler writes a status code outside 100-999, and
+informational 1xx codes other than 103 Early Hints are not meant to be written
+by handlers. Integer literals are reported when a named ` + "`http.Status*`" + `
+constant exists, with a suggested fix that replaces the literal.`,
+	Bad: `func Missing(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(404)
+}`,
+	Good: `func Missing(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+}`,
+}
+
+var ruleGoroutineWrite = &Rule{
+	ID:   "RL003",
+	Name: "goroutinewrite",
+	Doc:  "checks that the ResponseWriter is not used from goroutines that may outlive the handler",
+	Details: `A ResponseWriter must not be used after ` + "`ServeHTTP`" + ` returns. A goroutine
+started by the handler may still be running at that point, which is a data
+race and may write to a response that has already been sent.
+
+A goroutine is not reported when the handler waits for it on every path to
+a return, by calling Wait on a sync.WaitGroup the goroutine was given or
+by receiving from a channel it was given.`,
+	Bad: `func Stream(w http.ResponseWriter, r *http.Request) {
+	go func() {
+		w.Write(render())
+	}()
+}`,
+	Good: `func Stream(w http.ResponseWriter, r *http.Request) {
+	body := make(chan []byte)
+	go func() {
+		body <- render()
+	}()
+	w.Write(<-body)
+}`,
+}
+
+var ruleDeferredWrite = &Rule{
+	ID:   "RL004",
+	Name: "deferredwrite",
+	Doc:  "checks that deferred functions do not write a status after the handler may already have written one",
+	Details: `Deferred functions run after the handler body. If the body, or the next
+handler it calls, has already written a response, a deferred ` + "`WriteHeader()`" + `
+is dropped by ` + "`net/http`" + ` with a "superfluous response.WriteHeader call"
+warning, so the intended error status is never sent.`,
+	Bad: `func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}`,
+	Good: `func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("panic serving %s: %v", r.URL, err)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}`,
+}
+
+var ruleDoubleWrite = &Rule{
+	ID:   "RL005",
+	Name: "doublewrite",
+	Doc:  "checks that a handler does not write the response status more than once",
+	Details: `Only the first status written reaches the client. A later ` + "`WriteHeader()`" + `,
+including one after ` + "`Write()`" + ` has sent an implicit 200, is ignored. The rule
+is path-sensitive: it reports a call if any path reaching it has already
+written a status.`,
+	Bad: `func Create(w http.ResponseWriter, r *http.Request) {
+	if err := decode(r); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.WriteHeader(http.StatusCreated)
+}`,
+	Good: `func Create(w http.ResponseWriter, r *http.Request) {
+	if err := decode(r); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}`,
+}
+
+var ruleHeaderAfterWrite = &Rule{
+	ID:   "RL006",
+	Name: "headerafterwrite",
+	Doc:  "checks that response headers are not modified after the status has been written",
+	Details: `Headers are sent together with the status. Changing them with ` + "`Set`" + `, ` + "`Add`" + `,
+` + "`Del`" + ` or an index assignment after ` + "`WriteHeader()`" + ` or ` + "`Write()`" + ` has no
+effect.`,
+	Bad: `func Text(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}`,
+	Good: `func Text(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}`,
+}
+
+var ruleNextAfterReject = &Rule{
+	ID:   "RL007",
+	Name: "nextafterreject",
+	Doc:  "checks that middleware does not call the next handler after writing a response status",
+	Details: `Once middleware has written a status it has answered the request. Calling
+` + "`next.ServeHTTP`" + ` afterwards runs the protected handler anyway, which is
+usually an authorization bypass.`,
+	Bad: `func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+	Good: `func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+}
+
+var ruleMiddlewareChain = &Rule{
+	ID:   "RL008",
+	Name: "middlewarechain",
+	Doc:  "reports routes that run through middleware which continues after rejecting a request",
+	Details: `A middleware reported by ` + "`returnafterstatus`" + ` or ` + "`nextafterreject`" + ` affects
+every route it wraps. This rule resolves how middleware is composed at route
+registration, whether by nesting calls such as ` + "`Auth(Logging(h))`" + ` or with
+chi and gorilla/mux ` + "`Use`" + `, and reports each registration that runs through
+an unsafe middleware, so the exposed endpoints are visible where they are
+declared.`,
+	Bad: `func routes(mux *http.ServeMux) {
+	mux.Handle("GET /admin/users", Auth(http.HandlerFunc(listUsers)))
+}
+
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+	Good: `func routes(mux *http.ServeMux) {
+	mux.Handle("GET /admin/users", Auth(http.HandlerFunc(listUsers)))
+}
+
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+}
+
+var ruleUncheckedWrite = &Rule{
+	ID:   "RL009",
+	Name: "uncheckedwrite",
+	Doc:  "checks that errors from writing the response body are not discarded",
+	Details: `Writing the body fails when the client has gone away or the connection
+breaks, and the response is then truncated. A handler that drops the error of
+` + "`Write()`" + `, ` + "`io.Copy`" + ` or an encoder built on the ResponseWriter, such as
+` + "`json.NewEncoder(w).Encode`" + `, cannot log or count the failure. Only calls whose
+results are discarded entirely are reported; assign the error to ` + "`_`" + ` to
+ignore it deliberately. The rule can be limited to writes that may follow a
+constant error status of 400 or more with the
+` + "`-uncheckedwrite-error-status`" + ` flag.`,
+	Bad: `func Fail(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(apiError{Message: "internal error"})
+}`,
+	Good: `func Fail(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(apiError{Message: "internal error"}); err != nil {
+		log.Printf("writing error response: %v", err)
+	}
+}`,
+}
+
+var ruleContentType = &Rule{
+	ID:       "RL010",
+	Name:     "contenttype",
+	Doc:      "checks that the Content-Type header is set before an error status followed by a body",
+	Optional: true,
+	Details: `Clients decode error responses by their Content-Type, and a JSON error body
+sent without one is sniffed as ` + "`text/plain`" + `. The rule reports every
+` + "`WriteHeader()`" + ` call with a constant status of 400 or more that some path
+follows with a body write, unless ` + "`w.Header().Set(\"Content-Type\", ...)`" + `, an
+index assignment to ` + "`w.Header()`" + ` or a call to a configured Content-Type
+helper runs on every path to the call. The header must be set in the same
+function as the status. Helpers that set the header are named with the
+` + "`-content-type-helpers`" + ` flag.`,
+	Bad: `func NotFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(apiError{Message: "not found"})
+}`,
+	Good: `func NotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(apiError{Message: "not found"})
+}`,
+}
+
+var ruleStatusPolicy = &Rule{
+	ID:   "RL011",
+	Name: "statuspolicy",
+	Doc:  "checks that handlers only write the status codes the configured policies permit",
+	Details: `Teams often agree on which statuses a kind of handler may send, for instance
+that middleware only rejects requests with a 4xx or 5xx status, or that
+validation failures are never answered with 500. Status policies express such
+conventions in the configuration: each one is scoped by package, function
+name pattern, route pattern or handler kind, and allows or denies codes,
+classes such as ` + "`4xx`" + ` and ranges. The rule evaluates the constant status of
+every ` + "`WriteHeader()`" + `, ` + "`http.Error`" + ` and ` + "`http.Redirect`" + ` call through a handler's
+ResponseWriter against the policies in scope and names the violated policy.
+Without policies it reports nothing.`,
+	Bad: `// With {"name": "middleware-rejects", "kinds": ["middleware"], "allow": ["4xx", "5xx"]}
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+	Good: `// With {"name": "middleware-rejects", "kinds": ["middleware"], "allow": ["4xx", "5xx"]}
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}`,
+}
+
+// Rules lists every rule, in the order they are documented.
+var Rules = []*Rule{
+	ruleReturnAfterStatus,
+	ruleStatusCode,
+	ruleGoroutineWrite,
+	ruleDeferredWrite,
+	ruleDoubleWrite,
+	ruleHeaderAfterWrite,
+	ruleNextAfterReject,
+	ruleMiddlewareChain,
+	ruleUncheckedWrite,
+	ruleContentType,
+	ruleStatusPolicy,
+}
+
+// Analyzer checks that w.WriteHeader() calls in http.Handler middleware are
+// immediately followed by a return statement.
+var Analyzer = newRule(Engine, ruleReturnAfterStatus)
+
+// StatusCodeAnalyzer validates constant status codes passed to WriteHeader.
+var StatusCodeAnalyzer = newRule(Engine, ruleStatusCode)
+
+// GoroutineWriteAnalyzer reports uses of the ResponseWriter from goroutines
+// started by a handler.
+var GoroutineWriteAnalyzer = newRule(Engine, ruleGoroutineWrite)
+
+// DeferredWriteAnalyzer reports deferred WriteHeader calls that may run after
+// the handler has already written a response.
+var DeferredWriteAnalyzer = newRule(Engine, ruleDeferredWrite)
+
+// DoubleWriteAnalyzer reports WriteHeader calls that may run after the
+// response status has already been written.
+var DoubleWriteAnalyzer = newRule(Engine, ruleDoubleWrite)
+
+// HeaderAfterWriteAnalyzer reports header modifications that happen after
+// the response status has been written and so have no effect.
+var HeaderAfterWriteAnalyzer = newRule(Engine, ruleHeaderAfterWrite)
+
+// NextAfterRejectAnalyzer reports middleware that calls the next handler
+// after it has already written a response status.
+var NextAfterRejectAnalyzer = newRule(Engine, ruleNextAfterReject)
+
+// MiddlewareChainAnalyzer reports route registrations that run through
+// middleware which continues after rejecting a request.
+var MiddlewareChainAnalyzer = newRule(Engine, ruleMiddlewareChain)
+
+// UncheckedWriteAnalyzer reports writes to the response body whose errors
+// are discarded.
+var UncheckedWriteAnalyzer = newRule(Engine, ruleUncheckedWrite)
+
+// ContentTypeAnalyzer reports error statuses followed by a body without a
+// Content-Type header. Its rule is optional.
+var ContentTypeAnalyzer = newRule(Engine, ruleContentType)
+
+// StatusPolicyAnalyzer reports status codes that the configured status
+// policies do not permit.
+var StatusPolicyAnalyzer = newRule(Engine, ruleStatusPolicy)
+
+// Analyzers lists the analyzer of every rule, in the same order as Rules.
+var Analyzers = []*analysis.Analyzer{
+	Analyzer,
+	StatusCodeAnalyzer,
+	GoroutineWriteAnalyzer,
+	DeferredWriteAnalyzer,
+	DoubleWriteAnalyzer,
+	HeaderAfterWriteAnalyzer,
+	NextAfterRejectAnalyzer,
+	MiddlewareChainAnalyzer,
+	UncheckedWriteAnalyzer,
+	ContentTypeAnalyzer,
+	StatusPolicyAnalyzer,
+}
+
+// newRule returns an analyzer that reports the findings of engine for one
+// rule
+func newRule(engine *analysis.Analyzer, rule *Rule) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:     rule.Name,
+		Doc:      rule.Doc,
+		URL:      rule.URL(),
+		Requires: []*analysis.Analyzer{engine},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			result := pass.ResultOf[engine].(*Result)
+			for _, finding := range result.Findings {
+				if finding.Rule == rule {
+					pass.Report(finding.Diagnostic)
+				}
+			}
+			return nil, nil
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package analyzer
+
+import (
+	"reflect"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+)
+
+// httpSSA builds the SSA form of packages that import net/http, like
+// buildssa, and returns an empty result for all other packages. Because the
+// Engine exports facts, drivers run it on every dependency of the analyzed
+// packages, and only packages importing net/http can declare handlers,
+// response helpers or middleware.
+var httpSSA = &analysis.Analyzer{
+	Name:       "httpssa",
+	Doc:        "builds the SSA form of packages that import net/http",
+	Run:        runHTTPSSA,
+	Requires:   buildssa.Analyzer.Requires,
+	ResultType: reflect.TypeOf((*buildssa.SSA)(nil)),
+}
+
+func runHTTPSSA(pass *analysis.Pass) (interface{}, error) {
+	for _, imp := range pass.Pkg.Imports() {
+		if imp.Path() == "net/http" {
+			return buildssa.Analyzer.Run(pass)
+		}
+	}
+	return &buildssa.SSA{}, nil
+}
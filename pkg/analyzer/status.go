@@ -0,0 +1,174 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"strconv"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// statusConstants maps every status code declared by net/http to the name
+// of its http.Status* constant.
+var statusConstants = map[int64]string{
+	100: "StatusContinue",
+	101: "StatusSwitchingProtocols",
+	102: "StatusProcessing",
+	103: "StatusEarlyHints",
+
+	200: "StatusOK",
+	201: "StatusCreated",
+	202: "StatusAccepted",
+	203: "StatusNonAuthoritativeInfo",
+	204: "StatusNoContent",
+	205: "StatusResetContent",
+	206: "StatusPartialContent",
+	207: "StatusMultiStatus",
+	208: "StatusAlreadyReported",
+	226: "StatusIMUsed",
+
+	300: "StatusMultipleChoices",
+	301: "StatusMovedPermanently",
+	302: "StatusFound",
+	303: "StatusSeeOther",
+	304: "StatusNotModified",
+	305: "StatusUseProxy",
+	307: "StatusTemporaryRedirect",
+	308: "StatusPermanentRedirect",
+
+	400: "StatusBadRequest",
+	401: "StatusUnauthorized",
+	402: "StatusPaymentRequired",
+	403: "StatusForbidden",
+	404: "StatusNotFound",
+	405: "StatusMethodNotAllowed",
+	406: "StatusNotAcceptable",
+	407: "StatusProxyAuthRequired",
+	408: "StatusRequestTimeout",
+	409: "StatusConflict",
+	410: "StatusGone",
+	411: "StatusLengthRequired",
+	412: "StatusPreconditionFailed",
+	413: "StatusRequestEntityTooLarge",
+	414: "StatusRequestURITooLong",
+	415: "StatusUnsupportedMediaType",
+	416: "StatusRequestedRangeNotSatisfiable",
+	417: "StatusExpectationFailed",
+	418: "StatusTeapot",
+	421: "StatusMisdirectedRequest",
+	422: "StatusUnprocessableEntity",
+	423: "StatusLocked",
+	424: "StatusFailedDependency",
+	425: "StatusTooEarly",
+	426: "StatusUpgradeRequired",
+	428: "StatusPreconditionRequired",
+	429: "StatusTooManyRequests",
+	431: "StatusRequestHeaderFieldsTooLarge",
+	451: "StatusUnavailableForLegalReasons",
+
+	500: "StatusInternalServerError",
+	501: "StatusNotImplemented",
+	502: "StatusBadGateway",
+	503: "StatusServiceUnavailable",
+	504: "StatusGatewayTimeout",
+	505: "StatusHTTPVersionNotSupported",
+	506: "StatusVariantAlsoNegotiates",
+	507: "StatusInsufficientStorage",
+	508: "StatusLoopDetected",
+	510: "StatusNotExtended",
+	511: "StatusNetworkAuthenticationRequired",
+}
+
+// checkStatusCode validates the constant-folded argument of a WriteHeader
+// call. Non-constant arguments are left alone.
+func checkStatusCode(c *checker, callExpr *ast.CallExpr) {
+	if len(callExpr.Args) != 1 {
+		return
+	}
+	arg := callExpr.Args[0]
+
+	code, ok := constStatus(c.pass, arg)
+	if !ok {
+		return
+	}
+
+	switch {
+	case code < 100 || code > 999:
+		// net/http panics on these at runtime
+		c.reportf(ruleStatusCode, arg.Pos(), "invalid WriteHeader status code %d: must be in the range 100-999", code)
+	case code < 200 && code != 103:
+		c.reportf(ruleStatusCode, arg.Pos(), "WriteHeader with informational status code %d: only 103 Early Hints should be written by a handler", code)
+	default:
+		lit, ok := ast.Unparen(arg).(*ast.BasicLit)
+		if !ok {
+			return
+		}
+		name, ok := statusConstants[code]
+		if !ok {
+			return
+		}
+		// The message names the constant as the fix writes it; without a
+		// usable import there is no fix, and the package name is assumed
+		qualifier, fixable := httpQualifier(c.pass, callExpr.Pos())
+		if !fixable {
+			qualifier = "http."
+		}
+		replacement := qualifier + name
+		diag := analysis.Diagnostic{
+			Pos:     lit.Pos(),
+			End:     lit.End(),
+			Message: fmt.Sprintf("use %s instead of the integer literal %s", replacement, lit.Value),
+		}
+		if fixable {
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message: fmt.Sprintf("Replace %s with %s", lit.Value, replacement),
+				TextEdits: []analysis.TextEdit{{
+					Pos:     lit.Pos(),
+					End:     lit.End(),
+					NewText: []byte(replacement),
+				}},
+			}}
+		}
+		c.report(ruleStatusCode, diag)
+	}
+}
+
+// constStatus returns the value of expr if the type checker folded it to an
+// integer constant.
+func constStatus(pass *analysis.Pass, expr ast.Expr) (int64, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(tv.Value)
+}
+
+// httpQualifier returns the prefix needed to refer to a net/http identifier
+// from the file containing pos, e.g. "http." or "" for a dot import.
+func httpQualifier(pass *analysis.Pass, pos token.Pos) (string, bool) {
+	for _, file := range pass.Files {
+		if pos < file.Pos() || pos >= file.End() {
+			continue
+		}
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || path != "net/http" {
+				continue
+			}
+			if spec.Name == nil {
+				return "http.", true
+			}
+			switch spec.Name.Name {
+			case "_":
+				return "", false
+			case ".":
+				return "", true
+			default:
+				return spec.Name.Name + ".", true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"golang.org/x/tools/go/ssa"
+)
+
+// checkUncheckedWrites reports calls writing the response body through the
+// tracked writer of h whose results, and so their errors, are discarded.
+// When the configuration asks for error statuses only, a write is reported
+// only if some path reaching it has written a constant status of 400 or
+// more.
+func checkUncheckedWrites(c *checker, h *handler) {
+	aliases := h.aliases
+	var walk func(fn *ssa.Function)
+	walk = func(fn *ssa.Function) {
+		walkFlow(fn, aliases.writesErrorStatus, func(instr ssa.Instruction, errorStatus bool) {
+			call, ok := instr.(*ssa.Call)
+			if !ok || (c.config.uncheckedErrorStatusOnly && !errorStatus) {
+				return
+			}
+			name, ok := aliases.bodyWrite(call.Common())
+			if !ok {
+				return
+			}
+			if callExpr := c.index.calls[call.Pos()]; callExpr != nil && c.index.dropped[callExpr] {
+				c.reportf(ruleUncheckedWrite, callExpr.Pos(), "error writing the response with %s is not checked", name)
+			}
+		})
+		for _, anon := range fn.AnonFuncs {
+			walk(anon)
+		}
+	}
+	walk(h.Func)
+}
+
+// writesErrorStatus reports whether instr is a WriteHeader call through the
+// tracked writer with a constant error status
+func (a *writerAliases) writesErrorStatus(instr ssa.Instruction) bool {
+	call, ok := instr.(*ssa.Call)
+	if !ok {
+		return false
+	}
+	common := call.Common()
+	if recv := receiver(common); recv == nil || !a.values[recv] || !isStatusMethodCall(common) {
+		return false
+	}
+	code, ok := statusArg(common)
+	return ok && code >= 400
+}
+
+// bodyWrite reports whether call writes the response body through the
+// tracked writer and returns an error: a Write, WriteString or ReadFrom
+// call on the writer, an io.Copy, io.CopyN, io.CopyBuffer or io.WriteString
+// to it, or an Encode call on an encoder constructed from it, such as
+// json.NewEncoder(w). It also returns how to name the call in a message.
+func (a *writerAliases) bodyWrite(call *ssa.CallCommon) (string, bool) {
+	if recv := receiver(call); recv != nil {
+		switch name := methodName(call); name {
+		case "Write", "WriteString", "ReadFrom":
+			return name, a.refersTo(recv)
+		case "Encode":
+			enc, ok := recv.(*ssa.Call)
+			if !ok {
+				return "", false
+			}
+			for _, arg := range enc.Call.Args {
+				if a.refersTo(arg) {
+					return name, true
+				}
+			}
+		}
+		return "", false
+	}
+
+	callee := call.StaticCallee()
+	if callee == nil || callee.Pkg == nil || callee.Pkg.Pkg.Path() != "io" || len(call.Args) == 0 {
+		return "", false
+	}
+	switch callee.Name() {
+	case "Copy", "CopyN", "CopyBuffer", "WriteString":
+		return "io." + callee.Name(), a.refersTo(call.Args[0])
+	}
+	return "", false
+}
+
+// refersTo reports whether v is the tracked writer, possibly converted to
+// another interface such as io.Writer
+func (a *writerAliases) refersTo(v ssa.Value) bool {
+	switch conv := v.(type) {
+	case *ssa.ChangeInterface:
+		v = conv.X
+	case *ssa.MakeInterface:
+		v = conv.X
+	}
+	return a.values[v]
+}
@@ -0,0 +1,207 @@
+package analyzer
+
+import (
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// writerAliases is the set of SSA values that refer to a handler's incoming
+// http.ResponseWriter, either directly or through a wrapper constructed from
+// it. Values are collected across closures, so a captured writer is tracked
+// into the function literals that capture it.
+type writerAliases struct {
+	iface  *types.Interface
+	values map[ssa.Value]bool
+	// helpers identifies calls to functions that always write a status
+	// through the writer; nil if they are not known
+	helpers *statusHelpers
+}
+
+// trackWriter computes the aliases of the ResponseWriter parameter of fn.
+// It returns nil if fn has no ResponseWriter parameter.
+func trackWriter(fn *ssa.Function) *writerAliases {
+	param := responseWriterParam(fn)
+	if param == nil {
+		return nil
+	}
+	return trackValue(param)
+}
+
+// trackWriterParam computes the aliases of parameter i of fn, not counting
+// any receiver, which must be a ResponseWriter.
+func trackWriterParam(fn *ssa.Function, i int) *writerAliases {
+	if fn.Signature.Recv() != nil {
+		i++
+	}
+	return trackValue(fn.Params[i])
+}
+
+// trackValue computes the aliases of a ResponseWriter parameter
+func trackValue(param *ssa.Parameter) *writerAliases {
+	aliases := &writerAliases{
+		iface:  param.Type().Underlying().(*types.Interface),
+		values: make(map[ssa.Value]bool),
+	}
+
+	worklist := []ssa.Value{param}
+	aliases.values[param] = true
+	for len(worklist) > 0 {
+		v := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+
+		for _, derived := range aliases.derived(v) {
+			if !aliases.values[derived] {
+				aliases.values[derived] = true
+				worklist = append(worklist, derived)
+			}
+		}
+	}
+
+	return aliases
+}
+
+// responseWriterParam returns the parameter of fn declared as
+// http.ResponseWriter, if any.
+func responseWriterParam(fn *ssa.Function) *ssa.Parameter {
+	for _, param := range fn.Params {
+		if isResponseWriterType(param.Type()) {
+			return param
+		}
+	}
+	return nil
+}
+
+// isResponseWriterType reports whether t is the named type net/http.ResponseWriter
+func isResponseWriterType(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "net/http" && obj.Name() == "ResponseWriter"
+}
+
+// isWriter reports whether values of type t can stand in for the tracked
+// ResponseWriter, i.e. whether t implements http.ResponseWriter.
+func (a *writerAliases) isWriter(t types.Type) bool {
+	return types.Implements(t, a.iface)
+}
+
+// isCell reports whether t is a pointer to a writer, such as the heap cell of
+// a captured local variable or the address of a wrapper's embedded writer.
+func (a *writerAliases) isCell(t types.Type) bool {
+	ptr, ok := t.Underlying().(*types.Pointer)
+	return ok && a.isWriter(ptr.Elem())
+}
+
+// derived returns the values that alias the writer because they were built
+// from v: conversions, merges, wrappers, heap cells and closure bindings.
+func (a *writerAliases) derived(v ssa.Value) []ssa.Value {
+	var out []ssa.Value
+	add := func(v ssa.Value) {
+		out = append(out, v)
+	}
+
+	referrers := v.Referrers()
+	if referrers == nil {
+		return nil
+	}
+
+	for _, instr := range *referrers {
+		switch instr := instr.(type) {
+		case *ssa.ChangeInterface, *ssa.MakeInterface, *ssa.ChangeType, *ssa.Convert, *ssa.Phi:
+			val := instr.(ssa.Value)
+			if a.isWriter(val.Type()) || a.isCell(val.Type()) {
+				add(val)
+			}
+		case *ssa.TypeAssert:
+			// w.(http.Flusher) still refers to the same writer, and the
+			// comma-ok form is narrowed by the Extract case below.
+			add(instr)
+		case *ssa.Extract:
+			if a.isWriter(instr.Type()) {
+				add(instr)
+			}
+		case *ssa.Field:
+			if a.isWriter(instr.Type()) {
+				add(instr)
+			}
+		case *ssa.FieldAddr:
+			if a.isCell(instr.Type()) {
+				add(instr)
+			}
+		case *ssa.UnOp:
+			// Loading from a cell that holds the writer
+			if instr.Op == token.MUL && a.isWriter(instr.Type()) {
+				add(instr)
+			}
+		case *ssa.Store:
+			// Storing the writer into a local cell or into a wrapper
+			// struct makes that cell or wrapper an alias too.
+			if instr.Val != v {
+				continue
+			}
+			if root := addressRoot(instr.Addr); root != nil && (a.isWriter(root.Type()) || a.isCell(root.Type())) {
+				add(root)
+			}
+		case *ssa.Call:
+			// A constructor or method taking the writer and returning
+			// another writer wraps it, e.g. middleware.NewWrapResponseWriter(w).
+			if isStatusMethodCall(instr.Common()) {
+				continue
+			}
+			if a.isWriter(instr.Type()) {
+				add(instr)
+			} else if _, ok := instr.Type().(*types.Tuple); ok {
+				add(instr)
+			}
+		case *ssa.MakeClosure:
+			fn := instr.Fn.(*ssa.Function)
+			for i, binding := range instr.Bindings {
+				if binding == v {
+					add(fn.FreeVars[i])
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// addressRoot strips field and index selections from an address and returns
+// the value they were taken from.
+func addressRoot(addr ssa.Value) ssa.Value {
+	for {
+		switch a := addr.(type) {
+		case *ssa.FieldAddr:
+			addr = a.X
+		case *ssa.IndexAddr:
+			addr = a.X
+		default:
+			return addr
+		}
+	}
+}
+
+// isStatusMethodCall reports whether call invokes a method named WriteHeader
+func isStatusMethodCall(call *ssa.CallCommon) bool {
+	if call.IsInvoke() {
+		return call.Method.Name() == "WriteHeader"
+	}
+	callee := call.StaticCallee()
+	return callee != nil && callee.Signature.Recv() != nil && callee.Name() == "WriteHeader"
+}
+
+// receiver returns the value a method call is made on, or nil for a plain
+// function call.
+func receiver(call *ssa.CallCommon) ssa.Value {
+	if call.IsInvoke() {
+		return call.Value
+	}
+	if callee := call.StaticCallee(); callee != nil && callee.Signature.Recv() != nil && len(call.Args) > 0 {
+		return call.Args[0]
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package returnlintertest runs the returnlinter rules, configured for a
+// codebase, over fixture packages, so that the configuration can be
+// regression-tested along with the code it describes.
+//
+// Fixtures are laid out as for analysistest: packages live under dir/src,
+// and the lines expected to be reported carry a // want comment holding a
+// regular expression for the message:
+//
+//	func TestLinterConfig(t *testing.T) {
+//		cfg := analyzer.Config{
+//			ResponseHelpers: []string{"example.com/api.JSONError"},
+//			Loggers:         []string{"log/slog"},
+//		}
+//		returnlintertest.Run(t, analysistest.TestData(), cfg, "handlers")
+//		returnlintertest.NoFindings(t, analysistest.TestData(), cfg, "handlers/logging.go")
+//	}
+package returnlintertest
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// Run runs every rule but the optional ones, configured by cfg, over the
+// packages matching patterns and checks their findings against the // want
+// comments of the fixtures, as analysistest.Run does for a single analyzer.
+// Messages of findings in handlers registered on routes start with the
+// routes, e.g. "GET /admin: ".
+func Run(t analysistest.Testing, dir string, cfg analyzer.Config, patterns ...string) []*analysistest.Result {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	a, err := newAnalyzer(cfg)
+	if err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+	// GOPATH must be absolute
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+	return analysistest.Run(t, dir, a, patterns...)
+}
+
+// NoFindings checks that no rule but the optional ones, configured by cfg,
+// reports a finding in file, a fixture named by its path relative to
+// dir/src such as "handlers/logging.go". The rest of the file's package is
+// analyzed but not checked, and // want comments are ignored.
+func NoFindings(t analysistest.Testing, dir string, cfg analyzer.Config, file string) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	a, err := newAnalyzer(cfg)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	file = filepath.ToSlash(file)
+	pkgs, err := load(dir, "./"+path.Dir(file))
+	if err != nil {
+		t.Errorf("loading %s: %v", file, err)
+		return
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		t.Errorf("loading %s: packages contain errors", file)
+		return
+	}
+	graph, err := checker.Analyze([]*analysis.Analyzer{a}, pkgs, nil)
+	if err != nil {
+		t.Errorf("analyzing %s: %v", file, err)
+		return
+	}
+
+	src := filepath.Join(dir, "src")
+	found := false
+	type finding struct {
+		posn token.Position
+		msg  string
+	}
+	var findings []finding
+	// The package and its test variant share files; report each finding once
+	seen := make(map[finding]bool)
+	for _, act := range graph.Roots {
+		if act.Err != nil {
+			t.Errorf("analyzing %s: %v", act.Package.PkgPath, act.Err)
+			continue
+		}
+		for _, name := range act.Package.CompiledGoFiles {
+			found = found || fixtureName(src, name) == file
+		}
+		for _, diag := range act.Diagnostics {
+			posn := act.Package.Fset.Position(diag.Pos)
+			if fixtureName(src, posn.Filename) != file {
+				continue
+			}
+			f := finding{posn, diag.Category + ": " + diag.Message}
+			if !seen[f] {
+				seen[f] = true
+				findings = append(findings, f)
+			}
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].posn.Offset < findings[j].posn.Offset
+	})
+	for _, f := range findings {
+		t.Errorf("%s:%d:%d: unexpected finding: %s", file, f.posn.Line, f.posn.Column, f.msg)
+	}
+	if !found {
+		t.Errorf("%s: no such file in package %s", file, path.Dir(file))
+	}
+}
+
+// newAnalyzer returns an analyzer reporting the findings of the rules that
+// are not optional, configured by cfg. Optional rules can be checked with
+// analysistest and the analyzers of analyzer.New.
+func newAnalyzer(cfg analyzer.Config) (*analysis.Analyzer, error) {
+	linter, err := analyzer.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &analysis.Analyzer{
+		Name:     "returnlinter",
+		Doc:      "reports the findings of every returnlinter rule",
+		Requires: []*analysis.Analyzer{linter.Engine},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			result := pass.ResultOf[linter.Engine].(*analyzer.Result)
+			for _, finding := range result.Findings {
+				if !finding.Rule.Optional {
+					pass.Report(finding.Diagnostic)
+				}
+			}
+			return nil, nil
+		},
+	}, nil
+}
+
+// load loads the fixture packages matching pattern, relative to dir/src, in
+// GOPATH mode as analysistest does. dir must be absolute.
+func load(dir, pattern string) ([]*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  packages.LoadAllSyntax,
+		Dir:   filepath.Join(dir, "src"),
+		Tests: true,
+		Env:   append(os.Environ(), "GOPATH="+dir, "GO111MODULE=off", "GOWORK=off"),
+	}, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages matched %s", pattern)
+	}
+	return pkgs, nil
+}
+
+// fixtureName returns the name of a file relative to src, with slashes
+func fixtureName(src, name string) string {
+	rel, err := filepath.Rel(src, name)
+	if err != nil {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,106 @@
+package returnlintertest_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/3-2-1-contact/return-linter/pkg/analyzer"
+	"github.com/3-2-1-contact/return-linter/pkg/returnlintertest"
+)
+
+// testdata is the fixture tree shared with the analyzer tests
+var testdata = filepath.Join("..", "..", "testdata")
+
+// config is the configuration the configured fixture is written for
+var config = analyzer.Config{
+	ResponseHelpers: []string{"(configured.Responder).Reject"},
+	Loggers:         []string{"applog", "log/slog"},
+}
+
+// recorder collects the errors a helper reports instead of failing the test
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRun(t *testing.T) {
+	returnlintertest.Run(t, testdata, config, "configured")
+}
+
+// TestRunReports checks that Run fails when the configuration does not match
+// the // want comments
+func TestRunReports(t *testing.T) {
+	var r recorder
+	returnlintertest.Run(&r, testdata, analyzer.Config{}, "configured")
+	errors := strings.Join(r.errors, "\n")
+	for _, want := range []string{
+		"configured/clean.go:14:4: unexpected diagnostic: WriteHeader call not immediately followed by return statement",
+		"configured/configured.go:25: no diagnostic was reported matching",
+	} {
+		if !strings.Contains(errors, want) {
+			t.Errorf("errors do not contain %q:\n%s", want, errors)
+		}
+	}
+}
+
+func TestNoFindings(t *testing.T) {
+	returnlintertest.NoFindings(t, testdata, config, "configured/clean.go")
+}
+
+// TestNoFindingsReports checks that NoFindings fails when the configuration
+// does not cover the fixture
+func TestNoFindingsReports(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  analyzer.Config
+		file string
+		want []string
+	}{
+		{
+			name: "default config",
+			cfg:  analyzer.Config{},
+			file: "configured/clean.go",
+			want: []string{
+				"configured/clean.go:14:4: unexpected finding: RL001: WriteHeader call not immediately followed by return statement",
+				"configured/clean.go:26:4: unexpected finding: RL001: WriteHeader call not immediately followed by return statement",
+				"configured/clean.go:38:4: unexpected finding: RL001: WriteHeader call not immediately followed by return statement",
+			},
+		},
+		{
+			name: "file with findings",
+			cfg:  config,
+			file: "configured/configured.go",
+			want: []string{
+				"configured/configured.go:25:3: unexpected finding: RL007: next handler called after the response status was already written",
+				"configured/configured.go:33:4: unexpected finding: RL001: WriteHeader call not immediately followed by return statement",
+			},
+		},
+		{
+			name: "missing file",
+			cfg:  config,
+			file: "configured/missing.go",
+			want: []string{"configured/missing.go: no such file in package configured"},
+		},
+		{
+			name: "invalid config",
+			cfg:  analyzer.Config{ResponseHelpers: []string{"example.com/api"}},
+			file: "configured/clean.go",
+			want: []string{`response helper "example.com/api": want a function name such as example.com/api.JSONError`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r recorder
+			returnlintertest.NoFindings(&r, testdata, tt.cfg, tt.file)
+			if got, want := strings.Join(r.errors, "\n"), strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("got errors:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
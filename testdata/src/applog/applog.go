@@ -0,0 +1,13 @@
+// Package applog is a structured logger outside the standard library,
+// allowed between WriteHeader and return by the configuration the
+// returnlintertest tests use.
+package applog
+
+// Logger logs with a fixed set of attributes
+type Logger struct{}
+
+// Warn logs a warning
+func (l *Logger) Warn(msg string, args ...any) {}
+
+// Info logs through the default logger
+func Info(msg string, args ...any) {}
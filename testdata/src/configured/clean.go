@@ -0,0 +1,55 @@
+package configured
+
+import (
+	"log/slog"
+	"net/http"
+
+	"applog"
+)
+
+// GoodLogged logs through a configured logging package before returning
+func GoodLogged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			applog.Info("unauthorized", "path", r.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GoodLoggerMethod logs through a method of a configured logging package
+func GoodLoggerMethod(logger *applog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > 1<<20 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			logger.Warn("request too large")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GoodSlog logs through log/slog, which is configured as a logger
+func GoodSlog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodTrace {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			slog.Warn("trace rejected")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GoodRejectAndReturn returns after the configured helper writes the status
+func GoodRejectAndReturn(resp Responder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			resp.Reject(w, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
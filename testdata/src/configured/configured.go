@@ -0,0 +1,40 @@
+// Package configured is analyzed by the returnlintertest tests with a
+// Config that names Responder.Reject as a response helper and applog and
+// log/slog as loggers.
+package configured
+
+import (
+	"net/http"
+
+	"applog"
+)
+
+// Responder writes error responses. The engine cannot see through the
+// interface, so Reject is configured as a response helper.
+type Responder interface {
+	Reject(w http.ResponseWriter, code int)
+}
+
+// BadRejectThenNext rejects the request through the helper and still calls
+// the next handler
+func BadRejectThenNext(resp Responder, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			resp.Reject(w, http.StatusUnauthorized)
+		}
+		next.ServeHTTP(w, r) // want "next handler called after the response status was already written"
+	})
+}
+
+// BadTwoLogCalls logs twice before returning; a single log call is allowed
+func BadTwoLogCalls(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed) // want "WriteHeader call not immediately followed by return statement"
+			applog.Info("rejected", "method", r.Method)
+			applog.Info("done")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package contenttype
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// BadNoHeader writes a JSON error body without a Content-Type
+func BadNoHeader(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound) // want "error status written without setting the Content-Type header of the body that follows"
+	_ = json.NewEncoder(w).Encode(apiError{Message: "not found"})
+}
+
+// BadHeaderOnOnePath sets the header on one path to the status only
+func BadHeaderOnOnePath(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Accept") == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(http.StatusBadRequest) // want "error status written without setting the Content-Type header of the body that follows"
+	_, _ = w.Write([]byte(`{"message":"bad request"}`))
+}
+
+// BadHeaderAfterStatus sets the header once it can no longer be sent
+func BadHeaderAfterStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError) // want "error status written without setting the Content-Type header of the body that follows"
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"message":"internal error"}`))
+}
+
+// BadBodyOnOnePath writes a body after the status on one path
+func BadBodyOnOnePath(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusForbidden) // want "error status written without setting the Content-Type header of the body that follows"
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write([]byte("forbidden"))
+}
+
+// GoodSet sets the header before the status
+func GoodSet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_ = json.NewEncoder(w).Encode(apiError{Message: "not found"})
+}
+
+// GoodIndex assigns the header before the status on every path
+func GoodIndex(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if r.Method == http.MethodPost {
+		h["Content-Type"] = []string{"application/json"}
+	} else {
+		h.Set("Content-Type", "text/plain")
+	}
+	w.WriteHeader(http.StatusConflict)
+	_, _ = w.Write([]byte("conflict"))
+}
+
+// GoodNoBody writes an error status without a body
+func GoodNoBody(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// GoodSuccess writes a body after a success status
+func GoodSuccess(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte("created"))
+}
@@ -0,0 +1,54 @@
+package deferredwrite
+
+import "net/http"
+
+// BadRecover writes a status from a deferred recover after next may have
+// written one already
+func BadRecover(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError) // want "deferred WriteHeader may run after the handler has already written a status"
+			}
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BadDeferredCall defers the status write directly
+func BadDeferredCall(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer w.WriteHeader(http.StatusNoContent) // want "deferred WriteHeader may run after the handler has already written a status"
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	})
+}
+
+// GoodDeferredOnlyWrite defers the only write the handler makes
+func GoodDeferredOnlyWrite(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusNoContent
+		defer func() {
+			w.WriteHeader(status)
+		}()
+		if r.Method == "POST" {
+			status = http.StatusAccepted
+		}
+	})
+}
+
+// recoverer is a handler type whose ServeHTTP method is checked as well
+type recoverer struct {
+	next http.Handler
+}
+
+func (h recoverer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if recover() != nil {
+			w.WriteHeader(http.StatusInternalServerError) // want "deferred WriteHeader may run after the handler has already written a status"
+		}
+	}()
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,58 @@
+package doublewrite
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// BadSequential writes the status twice
+func BadSequential(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusInternalServerError) // want "WriteHeader may be called after the response status was already written"
+}
+
+// BadAfterBody writes a status after the body, which already sent 200
+func BadAfterBody(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hello"))
+	w.WriteHeader(http.StatusCreated) // want "WriteHeader may be called after the response status was already written"
+}
+
+// BadOnOnePath writes the status twice only when the request fails to decode
+func BadOnOnePath(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	w.WriteHeader(http.StatusOK) // want "WriteHeader may be called after the response status was already written"
+}
+
+// BadInLoop writes the status on every iteration
+func BadInLoop(w http.ResponseWriter, r *http.Request) {
+	for range r.Header {
+		w.WriteHeader(http.StatusOK) // want "WriteHeader may be called after the response status was already written"
+	}
+}
+
+// GoodBranches writes exactly one status on each path
+func GoodBranches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// GoodEarlyHints sends an informational status before the final one
+func GoodEarlyHints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Link", "</style.css>; rel=preload; as=style")
+	w.WriteHeader(http.StatusEarlyHints)
+	w.WriteHeader(http.StatusOK)
+}
+
+type api struct{}
+
+func (api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusAccepted) // want "WriteHeader may be called after the response status was already written"
+}
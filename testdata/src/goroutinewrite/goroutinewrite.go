@@ -0,0 +1,133 @@
+package goroutinewrite
+
+import (
+	"log"
+	"net/http"
+	"sync"
+)
+
+func audit(w http.ResponseWriter, status int) {
+	w.WriteHeader(status)
+}
+
+// BadGoroutineWrite writes the status from a goroutine
+func BadGoroutineWrite(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		go func() {
+			w.WriteHeader(http.StatusAccepted) // want "ResponseWriter used in a goroutine that may outlive the handler"
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BadGoroutineBody writes the body from a goroutine
+func BadGoroutineBody(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := w.Write([]byte("streaming")); err != nil { // want "ResponseWriter used in a goroutine that may outlive the handler"
+				log.Println(err)
+			}
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BadGoroutineArgument hands the writer to a function running in a goroutine
+func BadGoroutineArgument(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		go audit(w, http.StatusOK) // want "ResponseWriter passed to a goroutine that may outlive the handler"
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// GoodGoroutine does not touch the writer from the goroutine
+func GoodGoroutine(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		go func() {
+			log.Println("request for", path)
+		}()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// GoodGoroutineWaitGroup waits for the goroutine before returning
+func GoodGoroutineWaitGroup(w http.ResponseWriter, r *http.Request) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.WriteHeader(http.StatusAccepted)
+	}()
+	wg.Wait()
+}
+
+// GoodGoroutineDeferredWait defers the wait before starting the goroutine
+func GoodGoroutineDeferredWait(w http.ResponseWriter, r *http.Request) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, chunk := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := w.Write([]byte(chunk)); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+}
+
+// GoodGoroutineChannel receives from a channel the goroutine closes
+func GoodGoroutineChannel(w http.ResponseWriter, r *http.Request) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.WriteHeader(http.StatusAccepted)
+	}()
+	if r.Method == http.MethodHead {
+		log.Println("head request")
+	}
+	<-done
+}
+
+// GoodGoroutineRange drains the results the goroutine sends
+func GoodGoroutineRange(w http.ResponseWriter, r *http.Request) {
+	results := make(chan error)
+	go func() {
+		defer close(results)
+		_, err := w.Write([]byte("streaming"))
+		results <- err
+	}()
+	for err := range results {
+		log.Println(err)
+	}
+}
+
+// BadGoroutineWaitOnOnePath only waits for the goroutine on one path
+func BadGoroutineWaitOnOnePath(w http.ResponseWriter, r *http.Request) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.WriteHeader(http.StatusAccepted) // want "ResponseWriter used in a goroutine that may outlive the handler"
+	}()
+	if r.Method == http.MethodHead {
+		return
+	}
+	wg.Wait()
+}
+
+var ready = make(chan struct{})
+
+// BadGoroutineOtherChannel receives from a channel the goroutine never sees
+func BadGoroutineOtherChannel(w http.ResponseWriter, r *http.Request) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.WriteHeader(http.StatusAccepted) // want "ResponseWriter used in a goroutine that may outlive the handler"
+	}()
+	<-ready
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// Auth is middleware that rejects requests without credentials
+func Auth(next http.Handler) http.Handler { // want Auth:"unsafeMiddleware"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func register() {
+	http.HandleFunc("GET /health", Health)
+}
+
+// Health is a plain handler function
+func Health(w http.ResponseWriter, r *http.Request) { // want Health:`writesStatus\(0\)`
+	w.WriteHeader(http.StatusOK)
+}
+
+// Server handles requests through its ServeHTTP method
+type Server struct {
+	mux http.Handler
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) { // want ServeHTTP:`writesStatus\(0\)`
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// notAHandler has the wrong signature and is not summarized
+func notAHandler(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,37 @@
+package headerafterwrite
+
+import "net/http"
+
+// BadSetAfterStatus sets a header once the headers have been sent
+func BadSetAfterStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "text/plain") // want "response header modified after the status was written; the change has no effect"
+	w.Write([]byte("ok"))
+}
+
+// BadAddAfterBody adds a header after writing the body
+func BadAddAfterBody(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+	w.Header().Add("X-Done", "1") // want "response header modified after the status was written; the change has no effect"
+}
+
+// BadIndexAfterStatus assigns to the header map after writing the status
+func BadIndexAfterStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	w.Header()["X-Method"] = []string{r.Method} // want "response header modified after the status was written; the change has no effect"
+}
+
+// GoodSetBeforeStatus sets headers before writing the status
+func GoodSetBeforeStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// GoodReadAfterStatus only reads a header after writing the status
+func GoodReadAfterStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_ = w.Header().Get("Content-Type")
+}
@@ -0,0 +1,52 @@
+// Package httpx is a shared package of response helpers and middleware,
+// imported by other fixtures to test facts across package boundaries.
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error writes a JSON error response on every path
+func Error(w http.ResponseWriter, code int, msg string) { // want Error:`writesStatus\(0\)`
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "{\"error\": %q}\n", msg)
+}
+
+// Forbidden writes its status through a helper of the same package
+func Forbidden(w http.ResponseWriter) { // want Forbidden:`writesStatus\(0\)`
+	Error(w, http.StatusForbidden, "forbidden")
+}
+
+// Responder writes responses through a method
+type Responder struct{}
+
+// NotFound writes a status through its second parameter
+func (Responder) NotFound(r *http.Request, w http.ResponseWriter) { // want NotFound:`writesStatus\(1\)`
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// MaybeError only writes when err is not nil, so it is not a helper
+func MaybeError(w http.ResponseWriter, err error) {
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// Auth continues to the next handler after rejecting the request
+func Auth(next http.Handler) http.Handler { // want Auth:"unsafeMiddleware"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Logging never rejects a request
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package middlewarechain
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+
+	"httpx"
+)
+
+func register(m *http.ServeMux) {
+	m.Handle("GET /admin/users", Auth(Logging(http.HandlerFunc(listUsers)))) // want `route GET /admin/users runs through middleware Auth, which continues after rejecting the request`
+	m.Handle("/metrics", Logging(http.HandlerFunc(listUsers)))
+	m.Handle("/limited", Logging(RateLimit(http.HandlerFunc(listUsers)))) // want `route /limited runs through middleware RateLimit`
+	m.HandleFunc("/open", listUsers)
+	m.Handle("/shared", httpx.Logging(httpx.Auth(http.HandlerFunc(listUsers)))) // want `route /shared runs through middleware httpx.Auth, which continues after rejecting the request`
+
+	r := chi.NewRouter()
+	r.Use(Logging)
+	r.Route("/api", func(r chi.Router) {
+		r.Use(Auth)
+		r.Get("/orders", listUsers) // want `route GET /api/orders runs through middleware Auth`
+	})
+	r.Get("/status", listUsers)
+	r.With(RateLimit).Post("/upload", listUsers) // want `route POST /upload runs through middleware RateLimit`
+
+	g := mux.NewRouter()
+	g.Use(Auth)
+	g.HandleFunc("/v2/items", listUsers).Methods("GET") // want `route GET /v2/items runs through middleware Auth`
+}
+
+// Auth continues to the next handler after rejecting the request
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimit writes a body after rejecting the request
+func RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > 1<<20 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("slow down"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Logging is safe: it never rejects a request
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Println(r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func listUsers(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("[]"))
+}
@@ -0,0 +1,88 @@
+package nextafterreject
+
+import (
+	"log"
+	"net/http"
+
+	"httpx"
+)
+
+// BadFallsThrough rejects the request but still calls the next handler
+func BadFallsThrough(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			log.Println("unauthorized")
+		}
+		next.ServeHTTP(w, r) // want "next handler called after the response status was already written"
+	})
+}
+
+// BadAfterBody writes an error body and still calls the next handler
+func BadAfterBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > 1<<20 {
+			w.Write([]byte("request too large"))
+		}
+		next.ServeHTTP(w, r) // want "next handler called after the response status was already written"
+	})
+}
+
+// GoodReturns returns after rejecting the request
+func GoodReturns(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GoodEarlyHints sends an informational status and then continues
+func GoodEarlyHints(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BadSharedHelper rejects the request with a helper from another package,
+// known to write a status through its exported fact
+func BadSharedHelper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			httpx.Error(w, http.StatusUnauthorized, "unauthorized")
+		}
+		next.ServeHTTP(w, r) // want "next handler called after the response status was already written"
+	})
+}
+
+// BadSharedMethod rejects the request with a helper method
+func BadSharedMethod(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			httpx.Responder{}.NotFound(r, w)
+		}
+		next.ServeHTTP(w, r) // want "next handler called after the response status was already written"
+	})
+}
+
+// GoodSharedHelper returns after rejecting the request
+func GoodSharedHelper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			httpx.Forbidden(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GoodConditionalHelper calls a helper that does not always write a status
+func GoodConditionalHelper(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpx.MaybeError(w, nil)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -151,3 +151,71 @@ func BadMiddlewareWithLogWrongOrder(handler http.Handler) http.Handler {
 		handler.ServeHTTP(w, r)
 	})
 }
+
+// BadMiddlewareReturnAfterBlock shows that a return after the end of the
+// WriteHeader call's block does not count
+func BadMiddlewareReturnAfterBlock(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+		} else {
+			handler.ServeHTTP(w, r)
+		}
+		return
+	})
+}
+
+// GoodMiddlewareWithLogArguments shows that the arguments of the log call are
+// part of the log statement
+func GoodMiddlewareWithLogArguments(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			log.Printf("unauthorized request to %s from %s", r.URL.String(), r.RemoteAddr)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BadMiddlewareFallsOffEnd shows that reaching the end of the handler is not
+// an explicit return
+func BadMiddlewareFallsOffEnd(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+	})
+}
+
+// BadMiddlewareTwoLogs shows that only a single log call is allowed
+func BadMiddlewareTwoLogs(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+			log.Println("Unauthorized access attempt")
+			log.Println("Rejected")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BadMiddlewareNested shows that a handler literal nested in another is
+// checked once, including writes to the outer writer it captures
+func BadMiddlewareNested(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner := http.HandlerFunc(func(iw http.ResponseWriter, ir *http.Request) {
+			if ir.Header.Get("Authorization") == "" {
+				iw.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+			}
+			if r.Header.Get("X-Forbidden") != "" {
+				w.WriteHeader(http.StatusForbidden) // want "WriteHeader call not immediately followed by return statement"
+			}
+			handler.ServeHTTP(iw, ir)
+		})
+		inner.ServeHTTP(w, r)
+	})
+}
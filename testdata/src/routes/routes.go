@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+)
+
+func register() {
+	http.HandleFunc("/health", health)
+
+	m := http.NewServeMux()
+	m.HandleFunc("GET /admin/users", listUsers)
+	m.Handle("POST /admin/users", http.HandlerFunc(createUser))
+	m.Handle("/static/", &files{})
+	m.Handle("/private/", Auth(http.HandlerFunc(private)))
+	m.HandleFunc("DELETE /admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+		w.WriteHeader(http.StatusNoContent) // want `DELETE /admin/users/\{id\}: WriteHeader may be called after the response status was already written`
+	})
+
+	r := chi.NewRouter()
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/orders", listOrders)
+		r.Method("patch", "/orders/{id}", http.HandlerFunc(updateOrder))
+	})
+
+	g := mux.NewRouter()
+	sub := g.PathPrefix("/v2").Subrouter()
+	sub.HandleFunc("/items", items).Methods("GET", "HEAD")
+}
+
+func health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusOK) // want `/health: WriteHeader may be called`
+}
+
+func listUsers(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("[]"))
+	w.WriteHeader(http.StatusOK) // want `GET /admin/users: WriteHeader may be called`
+}
+
+func createUser(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusCreated)
+}
+
+type files struct{}
+
+func (f *files) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(http.StatusNotFound) // want `/static/: WriteHeader may be called`
+}
+
+// Auth guards the private handlers
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		w.WriteHeader(http.StatusOK) // want `/private/: WriteHeader may be called`
+		next.ServeHTTP(w, r)
+	})
+}
+
+func private(w http.ResponseWriter, r *http.Request) {}
+
+func listOrders(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusOK) // want `GET /api/orders: WriteHeader may be called`
+}
+
+func updateOrder(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusOK) // want `PATCH /api/orders/\{id\}: WriteHeader may be called`
+}
+
+func items(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusOK) // want `GET /v2/items, HEAD /v2/items: WriteHeader may be called`
+}
+
+// unrouted is not registered, so its diagnostics carry no route
+func unrouted(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusOK) // want `^WriteHeader may be called`
+}
@@ -0,0 +1,46 @@
+package statuscode
+
+import "net/http"
+
+const tooLarge = 2000
+
+// ZeroStatus writes a status code that net/http rejects
+func ZeroStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(0) // want "invalid WriteHeader status code 0: must be in the range 100-999"
+}
+
+// FoldedStatus writes an out-of-range code through a constant
+func FoldedStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(tooLarge) // want "invalid WriteHeader status code 2000: must be in the range 100-999"
+}
+
+// ComputedStatus writes an out-of-range code through a constant expression
+func ComputedStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK * 10) // want "invalid WriteHeader status code 2000: must be in the range 100-999"
+}
+
+// Informational writes a 1xx code other than Early Hints
+func Informational(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusContinue) // want "WriteHeader with informational status code 100: only 103 Early Hints should be written by a handler"
+}
+
+// EarlyHints is the one informational code handlers may write
+func EarlyHints(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusEarlyHints)
+	w.WriteHeader(http.StatusOK)
+}
+
+// LiteralStatus writes a literal for which a named constant exists
+func LiteralStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(404) // want "use http.StatusNotFound instead of the integer literal 404"
+}
+
+// UnnamedStatus writes a literal that has no named constant
+func UnnamedStatus(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(599)
+}
+
+// DynamicStatus writes a status that is not a constant
+func DynamicStatus(w http.ResponseWriter, r *http.Request, code int) {
+	w.WriteHeader(code)
+}
@@ -0,0 +1,56 @@
+package statuspolicy
+
+import "net/http"
+
+// Auth may only reject requests, under the middleware-rejects policy
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusOK) // want `status 200 violates policy "middleware-rejects": middleware must reject with a 4xx or 5xx status`
+			return
+		}
+		if r.Header.Get("X-Forwarded-Proto") == "http" {
+			http.Redirect(w, r, "https://example.com", http.StatusFound) // want `status 302 violates policy "middleware-rejects"`
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ValidateUser must not answer validation errors with 500, under the
+// no-500-validation policy
+func ValidateUser(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength == 0 {
+		code := http.StatusInternalServerError
+		http.Error(w, "empty body", code) // want `status 500 violates policy "no-500-validation"`
+		return
+	}
+	w.WriteHeader(http.StatusUnprocessableEntity)
+}
+
+// Admin serves the admin routes, which only answer 403 or 2xx
+func Admin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNotFound) // want `status 404 violates policy "admin-statuses"`
+	default:
+		w.WriteHeader(http.StatusForbidden)
+	}
+}
+
+// Public is not in the scope of any policy
+func Public(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// Dynamic writes a status that is not constant, which policies cannot check
+func Dynamic(w http.ResponseWriter, r *http.Request, code int) {
+	w.WriteHeader(code)
+}
+
+func routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/users", Admin)
+	mux.HandleFunc("/public", Public)
+}
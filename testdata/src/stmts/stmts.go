@@ -0,0 +1,239 @@
+// Package stmts exercises every statement kind in go/ast, either as the
+// statement enclosing a WriteHeader call or as the statement following it.
+package stmts
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+var (
+	requests int
+	events   = make(chan string, 1)
+)
+
+func render() []byte { return nil }
+
+// BlockStmt
+func Block(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		{
+			w.WriteHeader(http.StatusTeapot) // want "WriteHeader call not immediately followed by return statement"
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// IfStmt with an else-if chain
+func IfElse(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handler.ServeHTTP(w, r)
+		} else if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusOK)
+			return
+		} else {
+			w.WriteHeader(http.StatusMethodNotAllowed) // want "WriteHeader call not immediately followed by return statement"
+			w.Write([]byte("method not allowed"))
+		}
+	})
+}
+
+// SwitchStmt and CaseClause, including fallthrough into a returning case
+func Switch(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			w.WriteHeader(http.StatusConflict)
+			fallthrough
+		case "DELETE":
+			return
+		case "POST":
+			w.WriteHeader(http.StatusCreated) // want "WriteHeader call not immediately followed by return statement"
+			w.Write([]byte("created"))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// TypeSwitchStmt
+func TypeSwitch(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Context().Value("user").(type) {
+		case nil:
+			w.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+		case string:
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// SelectStmt and CommClause
+func Select(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		select {
+		case <-ctx.Done():
+			w.WriteHeader(http.StatusServiceUnavailable) // want "WriteHeader call not immediately followed by return statement"
+			w.Write([]byte("unavailable"))
+		case msg := <-events:
+			w.WriteHeader(http.StatusAccepted)
+			log.Println(msg)
+			return
+		default:
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ForStmt
+func For(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			if r.Header.Get("X-Retry") == "" {
+				w.WriteHeader(http.StatusTooManyRequests) // want "WriteHeader call not immediately followed by return statement"
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// RangeStmt
+func Range(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, value := range r.Header.Values("X-Token") {
+			if value == "" {
+				w.WriteHeader(http.StatusBadRequest) // want "WriteHeader call not immediately followed by return statement"
+				continue
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// LabeledStmt and BranchStmt breaking out of a labelled loop
+func Labeled(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	outer:
+		for _, values := range r.Header {
+			for _, value := range values {
+				if value == "deny" {
+					w.WriteHeader(http.StatusForbidden) // want "WriteHeader call not immediately followed by return statement"
+					break outer
+				}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BranchStmt jumping to a label at the end of the handler, which holds an
+// EmptyStmt rather than a return
+func Goto(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+			goto reject
+		}
+		handler.ServeHTTP(w, r)
+	reject:
+	})
+}
+
+// GoodGoto jumps to a label whose statement is a return
+func GoodGoto(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			goto reject
+		}
+		handler.ServeHTTP(w, r)
+	reject:
+		return
+	})
+}
+
+// DeclStmt following WriteHeader
+func Decl(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError) // want "WriteHeader call not immediately followed by return statement"
+		var body = render()
+		w.Write(body)
+	})
+}
+
+// SendStmt following WriteHeader
+func Send(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted) // want "WriteHeader call not immediately followed by return statement"
+		events <- r.URL.Path
+		return
+	})
+}
+
+// IncDecStmt following WriteHeader
+func IncDec(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests) // want "WriteHeader call not immediately followed by return statement"
+		requests++
+		return
+	})
+}
+
+// AssignStmt following WriteHeader
+func Assign(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests) // want "WriteHeader call not immediately followed by return statement"
+		requests = 0
+		return
+	})
+}
+
+// GoStmt following WriteHeader
+func Go(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted) // want "WriteHeader call not immediately followed by return statement"
+		go render()
+		return
+	})
+}
+
+// DeferStmt following WriteHeader
+func Defer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted) // want "WriteHeader call not immediately followed by return statement"
+		defer render()
+		return
+	})
+}
+
+// ExprStmt and ReturnStmt following WriteHeader
+func LogThenReturn(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		log.Println("accepted")
+		return
+	})
+}
+
+// ExprStmt calling panic after the log call, which is not a return
+func LogThenPanic(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError) // want "WriteHeader call not immediately followed by return statement"
+		log.Println("failed")
+		panic("failed")
+	})
+}
+
+// ExprStmt calling log.Fatal, an ordinary call that does not count as a
+// return
+func LogFatal(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError) // want "WriteHeader call not immediately followed by return statement"
+		log.Fatal("failed")
+	})
+}
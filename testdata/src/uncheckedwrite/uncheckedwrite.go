@@ -0,0 +1,83 @@
+package uncheckedwrite
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// BadWrite drops the error of a body write
+func BadWrite(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("internal error")) // want "error writing the response with Write is not checked"
+}
+
+// BadEncode drops the error of an encoder writing to the response
+func BadEncode(w http.ResponseWriter, r *http.Request) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(apiError{Message: "not found"}) // want "error writing the response with Encode is not checked"
+}
+
+// BadCopy drops the error of io.Copy and io.WriteString to the response
+func BadCopy(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, strings.NewReader("body")) // want "error writing the response with io.Copy is not checked"
+	io.WriteString(w, "trailer")          // want "error writing the response with io.WriteString is not checked"
+}
+
+// BadClosure drops the error inside a function literal of the handler
+func BadClosure(w http.ResponseWriter, r *http.Request) {
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(apiError{Message: msg}) // want "error writing the response with Encode is not checked"
+	}
+	fail("bad request")
+}
+
+// recorder wraps the writer it was built from
+type recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// BadWrapper drops the error of a write through a wrapper
+func BadWrapper(w http.ResponseWriter, r *http.Request) {
+	rec := &recorder{ResponseWriter: w}
+	rec.WriteString("ok") // want "error writing the response with WriteString is not checked"
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	return io.WriteString(r.ResponseWriter, s)
+}
+
+// GoodChecked handles every write error
+func GoodChecked(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	if _, err := w.Write([]byte("internal error")); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+	if err := json.NewEncoder(w).Encode(apiError{Message: "internal error"}); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
+
+// GoodDiscarded ignores the errors deliberately
+func GoodDiscarded(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("ok"))
+	_ = json.NewEncoder(w).Encode(apiError{})
+}
+
+// GoodOtherWriter drops errors of writes to something other than the
+// response
+func GoodOtherWriter(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("log line")
+	json.NewEncoder(&b).Encode(apiError{})
+	_, _ = io.WriteString(w, b.String())
+}
@@ -0,0 +1,101 @@
+package writers
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// statusRecorder wraps a ResponseWriter to remember the status code
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// wrapResponseWriter mimics constructors such as middleware.NewWrapResponseWriter
+func wrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	return &statusRecorder{ResponseWriter: w}
+}
+
+// BadReassigned writes through a copy of the handler's writer
+func BadReassigned(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := w
+		if r.Method != "GET" {
+			ww.WriteHeader(http.StatusMethodNotAllowed) // want "WriteHeader call not immediately followed by return statement"
+			ww.Write([]byte("method not allowed"))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BadWrapped writes through a wrapper constructed from the handler's writer
+func BadWrapped(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := wrapResponseWriter(w)
+		if r.Header.Get("Authorization") == "" {
+			ww.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+			ww.Write([]byte("unauthorized"))
+		}
+		handler.ServeHTTP(ww, r)
+	})
+}
+
+// BadStructWrapper writes through a wrapper struct literal
+func BadStructWrapper(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		if r.Header.Get("Authorization") == "" {
+			rec.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+			rec.Write([]byte("unauthorized"))
+		}
+		handler.ServeHTTP(rec, r)
+	})
+}
+
+// BadEmbeddedWriter writes through the writer embedded in a wrapper
+func BadEmbeddedWriter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := statusRecorder{ResponseWriter: w}
+		if r.Header.Get("Authorization") == "" {
+			rec.ResponseWriter.WriteHeader(http.StatusUnauthorized) // want "WriteHeader call not immediately followed by return statement"
+			rec.Write([]byte("unauthorized"))
+		}
+		handler.ServeHTTP(&rec, r)
+	})
+}
+
+// BadConditionalWriter writes through a variable assigned on only one branch
+func BadConditionalWriter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var out http.ResponseWriter = httptest.NewRecorder()
+		if r.Header.Get("X-Live") != "" {
+			out = w
+		}
+		out.WriteHeader(http.StatusAccepted) // want "WriteHeader call not immediately followed by return statement"
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// GoodRecorder writes to an unrelated recorder, which is not held to the rule
+func GoodRecorder(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(http.StatusTeapot)
+		handler.ServeHTTP(rec, r)
+		w.WriteHeader(rec.Code)
+		return
+	})
+}
+
+// GoodUnrelatedWriter writes to a writer that did not come from the handler
+func GoodUnrelatedWriter(handler http.Handler, other http.ResponseWriter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other.WriteHeader(http.StatusNoContent)
+		handler.ServeHTTP(w, r)
+	})
+}